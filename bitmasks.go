@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Bitmask describes flag-set type of integer field.
+type Bitmask struct {
+	// Type is name of generated flag-set type.
+	Type string `json:"type"`
+	// Flags maps flag name to its value.
+	Flags map[string]int64 `json:"flags"`
+}
+
+// Bitmasks maps "Struct.Field" of generated types to bitmask.
+type Bitmasks map[string]Bitmask
+
+// LoadBitmasks reads bitmasks from JSON file.
+func LoadBitmasks(path string) (Bitmasks, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var bitmasks Bitmasks
+	if err := json.Unmarshal(data, &bitmasks); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if err := bitmasks.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return bitmasks, nil
+}
+
+func (bitmasks Bitmasks) validate() error {
+	flags := make(map[string]map[string]int64)
+	for field, bitmask := range bitmasks {
+		parts := strings.Split(field, ".")
+		if len(parts) != 2 || !token.IsIdentifier(parts[0]) || !token.IsIdentifier(parts[1]) {
+			return fmt.Errorf("invalid field %q, expected Struct.Field", field)
+		}
+		if !token.IsIdentifier(bitmask.Type) {
+			return fmt.Errorf("%s: invalid type name %q", field, bitmask.Type)
+		}
+		for name := range bitmask.Flags {
+			if !token.IsIdentifier(bitmask.Type + name) {
+				return fmt.Errorf("%s: invalid flag name %q", field, name)
+			}
+		}
+
+		// fields may share type only with the same flags
+		if existing, ok := flags[bitmask.Type]; ok && !equalFlags(existing, bitmask.Flags) {
+			return fmt.Errorf("%s: type %s is declared with different flags", field, bitmask.Type)
+		}
+		flags[bitmask.Type] = bitmask.Flags
+	}
+	return nil
+}
+
+func equalFlags(a, b map[string]int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, val := range a {
+		if other, ok := b[name]; !ok || other != val {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldType returns flag-set type of the struct field.
+func (bitmasks Bitmasks) fieldType(structName, field string) (string, bool) {
+	bitmask, ok := bitmasks[structName+"."+field]
+	return bitmask.Type, ok
+}
+
+// declarations generates flag-set types with constants and methods.
+func (bitmasks Bitmasks) declarations() string {
+	types := make(map[string]map[string]int64)
+	for _, bitmask := range bitmasks {
+		types[bitmask.Type] = bitmask.Flags
+	}
+
+	var names []string
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, typ := range names {
+		flags := types[typ]
+		var flagNames []string
+		for name := range flags {
+			flagNames = append(flagNames, name)
+		}
+		sort.Strings(flagNames)
+		sort.SliceStable(flagNames, func(i, j int) bool {
+			return flags[flagNames[i]] < flags[flagNames[j]]
+		})
+
+		sb.WriteString("// " + typ + " is a set of flags.\n")
+		sb.WriteString("type " + typ + " int64\n\n")
+		if len(flagNames) > 0 {
+			sb.WriteString("const (\n")
+			for _, name := range flagNames {
+				sb.WriteString("\t" + typ + name + " " + typ + " = " + strconv.FormatInt(flags[name], 10) + "\n")
+			}
+			sb.WriteString(")\n\n")
+		}
+		sb.WriteString("// Has reports whether all bits of flag are set.\n")
+		sb.WriteString("func (f " + typ + ") Has(flag " + typ + ") bool {\n")
+		sb.WriteString("\treturn f&flag == flag\n")
+		sb.WriteString("}\n\n")
+		sb.WriteString("// Set returns flags with bits of flag set.\n")
+		sb.WriteString("func (f " + typ + ") Set(flag " + typ + ") " + typ + " {\n")
+		sb.WriteString("\treturn f | flag\n")
+		sb.WriteString("}\n\n")
+	}
+	return sb.String()
+}
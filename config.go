@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v2"
+)
+
+// configFiles are looked up in working directory if config path is unset.
+var configFiles = []string{"vkgen.json", "vkgen.yaml", "vkgen.yml"}
+
+// findConfig returns path of config file of working directory, empty if
+// there is none.
+func findConfig() string {
+	for _, name := range configFiles {
+		if _, err := os.Stat(name); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// LoadConfig reads flag values from JSON or YAML file by its extension.
+// Keys are flag names, values of repeated flags are lists, e.g.
+//
+//	{"nofmt": true, "int-type": "int32", "fieldtags": ["db:{snake}"]}
+func LoadConfig(path string) (map[string]interface{}, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config map[string]interface{}
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &config)
+	default:
+		err = json.Unmarshal(data, &config)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+// applyConfig sets flags of config which are not set on command line.
+func applyConfig(c *cli.Context, config map[string]interface{}) error {
+	flags := make(map[string]bool)
+	for _, flag := range c.App.Flags {
+		for _, name := range flag.Names() {
+			flags[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(config))
+	for name := range config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !flags[name] || name == "config" {
+			return fmt.Errorf("unknown flag: %s", name)
+		}
+		if c.IsSet(name) {
+			continue
+		}
+
+		values := []interface{}{config[name]}
+		if list, ok := config[name].([]interface{}); ok {
+			values = list
+		}
+		for _, value := range values {
+			s, err := configValue(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			if err := c.Set(name, s); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// configValue formats config value as command-line flag value.
+func configValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", value)
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// deepCopier generates DeepCopy methods of types declared in generated
+// source. Fields of types declared elsewhere, interfaces and
+// functions are copied shallowly.
+type deepCopier struct {
+	fset *token.FileSet
+	// copied are names of declared types having DeepCopy: structs, maps,
+	// slices, pointers and defined types of them.
+	copied map[string]bool
+}
+
+// deepCopyMethods returns DeepCopy methods of types of src. Rules of
+// the file are applied first, so copies follow patched field types.
+func (g Generator) deepCopyMethods(file, src string) (string, error) {
+	patched := []byte("package " + pkgName + "\n" + src)
+	if structs := g.rules[file]; len(structs) > 0 {
+		var err error
+		patched, err = patchSource(patched, structs)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", file, err)
+		}
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", patched, 0)
+	if err != nil {
+		return "", err
+	}
+
+	var specs, aliases []*ast.TypeSpec
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			if ts := spec.(*ast.TypeSpec); ts.Assign.IsValid() {
+				aliases = append(aliases, ts)
+			} else {
+				specs = append(specs, ts)
+			}
+		}
+	}
+
+	d := deepCopier{fset: fset, copied: make(map[string]bool)}
+	for _, ts := range specs {
+		if d.sharesMemory(ts.Type) {
+			d.copied[ts.Name.Name] = true
+		}
+	}
+	// defined types and aliases of copied types, e.g. type A B, aliases
+	// share methods of their types
+	for changed := true; changed; {
+		changed = false
+		for _, ts := range append(specs, aliases...) {
+			if ident, ok := ts.Type.(*ast.Ident); ok && d.copied[ident.Name] && !d.copied[ts.Name.Name] {
+				d.copied[ts.Name.Name] = true
+				changed = true
+			}
+		}
+	}
+
+	var sb strings.Builder
+	for _, ts := range specs {
+		name := ts.Name.Name
+		if !d.copied[name] {
+			continue
+		}
+		sb.WriteString("// DeepCopy returns copy of x sharing no slices, maps and pointers with it.\n")
+		sb.WriteString("func (x *" + name + ") DeepCopy() *" + name + " {\n")
+		switch typ := ts.Type.(type) {
+		case *ast.Ident:
+			sb.WriteString("\treturn (*" + name + ")((*" + typ.Name + ")(x).DeepCopy())\n")
+		case *ast.StructType:
+			sb.WriteString("\tif x == nil {\n")
+			sb.WriteString("\t\treturn nil\n")
+			sb.WriteString("\t}\n")
+			sb.WriteString("\tc := *x\n")
+			sb.WriteString(d.copyFields("c", "x", typ, 0))
+			sb.WriteString("\treturn &c\n")
+		default:
+			sb.WriteString("\tif x == nil {\n")
+			sb.WriteString("\t\treturn nil\n")
+			sb.WriteString("\t}\n")
+			sb.WriteString("\tc := *x\n")
+			sb.WriteString(d.copyValue("c", "(*x)", typ, 0))
+			sb.WriteString("\treturn &c\n")
+		}
+		sb.WriteString("}\n\n")
+	}
+	return sb.String(), nil
+}
+
+// sharesMemory reports whether shallow copy of value of underlying type typ
+// shares memory with the value.
+func (d deepCopier) sharesMemory(typ ast.Expr) bool {
+	switch typ := typ.(type) {
+	case *ast.StructType, *ast.MapType, *ast.StarExpr:
+		return true
+	case *ast.ArrayType:
+		return typ.Len == nil
+	case *ast.SelectorExpr:
+		pkg, ok := typ.X.(*ast.Ident)
+		return ok && pkg.Name == "json" && typ.Sel.Name == "RawMessage"
+	}
+	return false
+}
+
+// copyFields returns statements replacing fields of dst struct shallowly
+// copied from src with their deep copies.
+func (d deepCopier) copyFields(dst, src string, st *ast.StructType, depth int) string {
+	var sb strings.Builder
+	for _, field := range st.Fields.List {
+		names := field.Names
+		if len(names) == 0 {
+			// embedded field is named after its type
+			name := field.Type
+			if star, ok := name.(*ast.StarExpr); ok {
+				name = star.X
+			}
+			ident, ok := name.(*ast.Ident)
+			if !ok {
+				continue
+			}
+			names = []*ast.Ident{ident}
+		}
+		for _, name := range names {
+			sb.WriteString(d.copyValue(dst+"."+name.Name, src+"."+name.Name, field.Type, depth))
+		}
+	}
+	return sb.String()
+}
+
+// copyValue returns statements replacing dst, which is shallow copy of
+// addressable src of type typ, with deep copy. It is empty if shallow copy
+// is deep.
+func (d deepCopier) copyValue(dst, src string, typ ast.Expr, depth int) string {
+	suffix := strconv.Itoa(depth)
+	switch typ := typ.(type) {
+	case *ast.Ident:
+		if d.copied[typ.Name] {
+			return "\t" + dst + " = *" + src + ".DeepCopy()\n"
+		}
+	case *ast.SelectorExpr:
+		if pkg, ok := typ.X.(*ast.Ident); ok && pkg.Name == "json" && typ.Sel.Name == "RawMessage" {
+			return "\tif " + src + " != nil {\n" +
+				"\t\t" + dst + " = append(json.RawMessage(nil), " + src + "...)\n" +
+				"\t}\n"
+		}
+	case *ast.StarExpr:
+		if ident, ok := typ.X.(*ast.Ident); ok && d.copied[ident.Name] {
+			return "\t" + dst + " = " + src + ".DeepCopy()\n"
+		}
+		v := "v" + suffix
+		return "\tif " + src + " != nil {\n" +
+			"\t\t" + v + " := *" + src + "\n" +
+			indent(d.copyValue(v, "(*"+src+")", typ.X, depth+1)) +
+			"\t\t" + dst + " = &" + v + "\n" +
+			"\t}\n"
+	case *ast.ArrayType:
+		i := "i" + suffix
+		elem := d.copyValue(dst+"["+i+"]", src+"["+i+"]", typ.Elt, depth+1)
+		if typ.Len != nil {
+			// arrays are copied by value
+			if elem == "" {
+				return ""
+			}
+			return "\tfor " + i + " := range " + src + " {\n" + elem + "\t}\n"
+		}
+		sb := "\tif " + src + " != nil {\n" +
+			"\t\t" + dst + " = make(" + d.exprString(typ) + ", len(" + src + "))\n" +
+			"\t\tcopy(" + dst + ", " + src + ")\n"
+		if elem != "" {
+			sb += "\t\tfor " + i + " := range " + src + " {\n" + indent(indent(elem)) + "\t\t}\n"
+		}
+		return sb + "\t}\n"
+	case *ast.MapType:
+		k, v := "k"+suffix, "v"+suffix
+		value := d.copyValue(v, v, typ.Value, depth+1)
+		return "\tif " + src + " != nil {\n" +
+			"\t\t" + dst + " = make(" + d.exprString(typ) + ", len(" + src + "))\n" +
+			"\t\tfor " + k + ", " + v + " := range " + src + " {\n" +
+			indent(indent(value)) +
+			"\t\t\t" + dst + "[" + k + "] = " + v + "\n" +
+			"\t\t}\n" +
+			"\t}\n"
+	case *ast.StructType:
+		return d.copyFields(dst, src, typ, depth)
+	}
+	return ""
+}
+
+// indent indents statements by tab.
+func indent(stmts string) string {
+	if stmts == "" {
+		return ""
+	}
+	return "\t" + strings.ReplaceAll(strings.TrimSuffix(stmts, "\n"), "\n", "\n\t") + "\n"
+}
+
+// exprString returns source of type expression.
+func (d deepCopier) exprString(expr ast.Expr) string {
+	var sb strings.Builder
+	if err := printer.Fprint(&sb, d.fset, expr); err != nil {
+		panic(err)
+	}
+	return sb.String()
+}
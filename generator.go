@@ -2,31 +2,294 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"go/format"
+	"go/token"
 	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/cqln/vkgen/schema"
+	"github.com/tidwall/gjson"
 )
 
 const (
 	genPrefix = "// Code generated by vkgen; DO NOT EDIT."
 	pkgName   = "generated"
+	emptyName = "Empty"
 )
 
+// Policies for objects without properties, type and reference.
+const (
+	EmptyObjectStruct     = "empty-struct"
+	EmptyObjectRawMessage = "raw-message"
+	EmptyObjectAny        = "any"
+)
+
+var emptyObjectPolicies = map[string]string{
+	EmptyObjectStruct:     "struct {\n}",
+	EmptyObjectRawMessage: "json.RawMessage",
+	EmptyObjectAny:        "interface{}",
+}
+
+// fieldTagNames are transforms of JSON field names available in templates
+// of additional struct tags.
+var fieldTagNames = map[string]func(string) string{
+	"{name}":  func(name string) string { return name },
+	"{snake}": snakeCase,
+	"{camel}": camelCase,
+}
+
+var fieldTagPlaceholder = regexp.MustCompile(`\{[a-z]*\}`)
+
+// ValidateFieldTag checks template of additional struct tag, e.g. db:{snake}.
+func ValidateFieldTag(template string) error {
+	i := strings.IndexByte(template, ':')
+	if i <= 0 || strings.ContainsAny(template[:i], " \"`") {
+		return fmt.Errorf("field tag %q: must be key:value", template)
+	}
+	for _, placeholder := range fieldTagPlaceholder.FindAllString(template[i+1:], -1) {
+		if _, ok := fieldTagNames[placeholder]; !ok {
+			return fmt.Errorf("field tag %q: unknown placeholder %s", template, placeholder)
+		}
+	}
+	return nil
+}
+
+func IsValidEmptyObjectPolicy(policy string) bool {
+	_, ok := emptyObjectPolicies[policy]
+	return ok
+}
+
+// Types of inline objects without properties and expressions without
+// type.
+const (
+	UnknownAny        = "any"
+	UnknownRawMessage = "raw-message"
+	UnknownMap        = "map"
+)
+
+var unknownTypes = map[string]string{
+	UnknownAny:        "interface{}",
+	UnknownRawMessage: "json.RawMessage",
+	UnknownMap:        "map[string]interface{}",
+}
+
+func IsValidUnknownType(typ string) bool {
+	_, ok := unknownTypes[typ]
+	return ok
+}
+
+// Receiver kinds of methods of generated structs.
+const (
+	ReceiverPointer = "pointer"
+	ReceiverValue   = "value"
+)
+
+func IsValidReceiver(kind string) bool {
+	return kind == ReceiverPointer || kind == ReceiverValue
+}
+
+// Modes of optional fields of objects and responses: pointers with
+// omitempty tags or values with omitempty tags.
+const (
+	OptionalPointer   = "pointer"
+	OptionalOmitempty = "omitempty"
+)
+
+func IsValidOptionalMode(mode string) bool {
+	return mode == OptionalPointer || mode == OptionalOmitempty
+}
+
+// IsValidPrefix reports whether prefix keeps type names exported
+// identifiers.
+func IsValidPrefix(prefix string) bool {
+	if prefix == "" {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(prefix)
+	return unicode.IsUpper(r) && token.IsIdentifier(prefix)
+}
+
+// integerTypes are Go types of schema integers.
+var integerTypes = map[string]struct{}{
+	"int":   {},
+	"int32": {},
+	"int64": {},
+}
+
+func IsValidIntegerType(typ string) bool {
+	_, ok := integerTypes[typ]
+	return ok
+}
+
 type Generator struct {
-	parser        *schema.Parser
-	nofmt         bool
-	nogoify       bool
-	debug         bool
-	goifyReplacer *strings.Replacer
+	parser          *schema.Parser
+	nofmt           bool
+	nogoify         bool
+	debug           bool
+	strictEnums     bool
+	emptyObjects    string
+	unknownType     string
+	probe           string
+	tests           string
+	comparable      []string
+	fieldTags       []string
+	extendedMerge   bool
+	defaultTags     bool
+	preserveUnknown bool
+	context         bool
+	enumIntBacked   bool
+	perCallToken    bool
+	lenientParams   bool
+	summaries       bool
+	sortFields      bool
+	generics        bool
+	timeFormat      bool
+	oneOfInterfaces bool
+	jsonNumber      bool
+	getters         bool
+	constructors    bool
+	wrapErrors      bool
+	intType         string
+	receiverKind    string
+	optionalMode    string
+	urlValues       bool
+	reqDefaults     bool
+	stamp           bool
+	examples        bool
+	ifChanged       bool
+	deepCopy        bool
+	noComments      bool
+	sdkImport       string
+	prefix          string
+	command         string
+	split           string
+	commentWidth    int
+	rules           Rules
+	interfaces      Interfaces
+	bitmasks        Bitmasks
+	source          *SchemaSource
+	schemaPaths     map[schema.SchemaType]string
+	parsed          *parsedSchemas
+	inline          *inlineStructs
+	dryRun          *dryRunFiles
+	goifyReplacer   *strings.Replacer
+	options         Options
+}
+
+// Defaults of options left zero.
+const (
+	DefaultIntType       = "int64"
+	DefaultSDKImport     = "github.com/SevereCloud/vksdk/api"
+	DefaultSchemaTimeout = 30 * time.Second
+)
+
+// Options configure generator, every field corresponds to a command-line
+// flag. Zero value generates the same code as vkgen run without flags
+// except CommentWidth, which disables wrapping of descriptions if zero.
+type Options struct {
+	Nofmt           bool
+	Nogoify         bool
+	Debug           bool
+	StrictEnums     bool
+	ExtendedMerge   bool
+	DefaultTags     bool
+	DedupInline     bool
+	NamedInline     bool
+	PreserveUnknown bool
+	Context         bool
+	EnumIntBacked   bool
+	PerCallToken    bool
+	LenientParams   bool
+	Summaries       bool
+	SortFields      bool
+	Generics        bool
+	Time            bool
+	OneOfInterfaces bool
+	JSONNumber      bool
+	Getters         bool
+	Constructors    bool
+	WrapErrors      bool
+	URLValues       bool
+	RequestDefaults bool
+	Stamp           bool
+	Examples        bool
+	DeepCopy        bool
+	DryRun          bool
+	IfChanged       bool
+	NoComments      bool
+	// EmptyObjects is EmptyObjectStruct if empty.
+	EmptyObjects string
+	// UnknownType is UnknownAny if empty.
+	UnknownType string
+	// IntType is DefaultIntType if empty.
+	IntType string
+	// Receiver is ReceiverPointer if empty.
+	Receiver string
+	// OptionalMode is OptionalPointer if empty.
+	OptionalMode string
+	Probe        string
+	// Tests is directory with response fixtures, round-trip tests are not
+	// generated if empty.
+	Tests string
+	// SDKImport is DefaultSDKImport if empty.
+	SDKImport string
+	// Prefix is prepended to names of generated types.
+	Prefix string
+	// Command reproducing the run, go:generate directive is omitted if
+	// empty.
+	Command      string
+	Split        string
+	CommentWidth int
+	Comparable   []string
+	FieldTags    []string
+	Rules        Rules
+	Interfaces   Interfaces
+	Bitmasks     Bitmasks
+	// Source reads schemas, it has DefaultSchemaTimeout if nil.
+	Source *SchemaSource
+	// SchemaPaths are file paths or URLs of schemas, missing schemas are
+	// read from files named after schema type, errors are not generated
+	// unless errors schema is set.
+	SchemaPaths map[schema.SchemaType]string
 }
 
-func NewGenerator(nofmt, nogoify, debug bool, objectsSchema []byte) Generator {
+func NewGenerator(opts Options, objectsSchema []byte) Generator {
+	if opts.EmptyObjects == "" {
+		opts.EmptyObjects = EmptyObjectStruct
+	}
+	if opts.UnknownType == "" {
+		opts.UnknownType = UnknownAny
+	}
+	if opts.IntType == "" {
+		opts.IntType = DefaultIntType
+	}
+	if opts.Receiver == "" {
+		opts.Receiver = ReceiverPointer
+	}
+	if opts.OptionalMode == "" {
+		opts.OptionalMode = OptionalPointer
+	}
+	if opts.SDKImport == "" {
+		opts.SDKImport = DefaultSDKImport
+	}
+	if opts.Source == nil {
+		opts.Source = NewSchemaSource(DefaultSchemaTimeout)
+	}
+
 	repl := []string{
 		"_", "",
 		" ", "",
@@ -41,242 +304,1221 @@ func NewGenerator(nofmt, nogoify, debug bool, objectsSchema []byte) Generator {
 		"Url", "URL",
 	}
 
+	var inline *inlineStructs
+	if opts.DedupInline || opts.NamedInline {
+		inline = newInlineStructs(opts.DedupInline, opts.NamedInline, opts.Prefix)
+	}
+
+	var dry *dryRunFiles
+	if opts.DryRun {
+		dry = &dryRunFiles{changed: make(map[string]bool)}
+	}
+
 	return Generator{
-		parser:        schema.NewParser(objectsSchema),
-		nofmt:         nofmt,
-		nogoify:       nogoify,
-		debug:         debug,
-		goifyReplacer: strings.NewReplacer(repl...),
+		parser:          schema.NewParser(objectsSchema),
+		nofmt:           opts.Nofmt,
+		nogoify:         opts.Nogoify,
+		debug:           opts.Debug,
+		strictEnums:     opts.StrictEnums,
+		emptyObjects:    opts.EmptyObjects,
+		unknownType:     opts.UnknownType,
+		intType:         opts.IntType,
+		receiverKind:    opts.Receiver,
+		optionalMode:    opts.OptionalMode,
+		probe:           opts.Probe,
+		tests:           opts.Tests,
+		comparable:      opts.Comparable,
+		fieldTags:       opts.FieldTags,
+		extendedMerge:   opts.ExtendedMerge,
+		defaultTags:     opts.DefaultTags,
+		preserveUnknown: opts.PreserveUnknown,
+		context:         opts.Context,
+		enumIntBacked:   opts.EnumIntBacked,
+		perCallToken:    opts.PerCallToken,
+		lenientParams:   opts.LenientParams,
+		summaries:       opts.Summaries,
+		sortFields:      opts.SortFields,
+		generics:        opts.Generics,
+		timeFormat:      opts.Time,
+		oneOfInterfaces: opts.OneOfInterfaces,
+		jsonNumber:      opts.JSONNumber,
+		getters:         opts.Getters,
+		constructors:    opts.Constructors,
+		wrapErrors:      opts.WrapErrors,
+		urlValues:       opts.URLValues,
+		reqDefaults:     opts.RequestDefaults,
+		stamp:           opts.Stamp,
+		examples:        opts.Examples,
+		ifChanged:       opts.IfChanged,
+		deepCopy:        opts.DeepCopy,
+		noComments:      opts.NoComments,
+		sdkImport:       opts.SDKImport,
+		prefix:          opts.Prefix,
+		command:         opts.Command,
+		split:           opts.Split,
+		commentWidth:    opts.CommentWidth,
+		rules:           opts.Rules,
+		interfaces:      opts.Interfaces,
+		bitmasks:        opts.Bitmasks,
+		source:          opts.Source,
+		schemaPaths:     opts.SchemaPaths,
+		parsed:          &parsedSchemas{},
+		inline:          inline,
+		dryRun:          dry,
+		goifyReplacer:   strings.NewReplacer(repl...),
+		options:         opts,
 	}
 }
 
 func (g Generator) Generate() (err error) {
-	err = g.generateObjects()
-	if err != nil {
-		return err
+	var hash string
+	if g.ifChanged {
+		hash, err = g.inputHash()
+		if err != nil {
+			return fmt.Errorf("schema hash: %w", err)
+		}
+		if generatedHash() == hash {
+			fmt.Println("schemas and options are unchanged, generation skipped")
+			return nil
+		}
 	}
 
-	err = g.generateResponses()
+	responsesSchema, err := g.readSchema(schema.ResponsesSchema)
 	if err != nil {
 		return fmt.Errorf("responses: %w", err)
 	}
+	g.parser.SetResponses(responsesSchema)
 
-	err = g.generateMethods()
-	if err != nil {
-		return fmt.Errorf("methods: %w", err)
+	passes := []struct {
+		name string
+		fn   func() error
+	}{
+		{"", g.generateObjects},
+		{"responses", g.generateResponses},
+		{"methods", g.generateMethods},
+		{"methods type-safe", g.generateMethodsTypeSafe},
+		{"builders", g.generateBuilders},
+		{"requests", g.generateRequests},
+		{"method names", g.generateMethodNames},
+		{"method responses", g.generateMethodResponses},
+		{"errors", g.generateErrors},
+		{"client", g.generateClient},
+		{"support", g.generateSupport},
+		{"generic", g.generateGeneric},
+		{"validation", g.generateValidation},
+		{"flexbool", g.generateFlexBool},
+		{"comparable", g.generateComparable},
+		{"probe", g.generateProbe},
+		{"tests", g.generateTests},
+		{"registry", g.generateRegistry},
 	}
 
-	err = g.generateMethodsTypeSafe()
-	if err != nil {
-		return fmt.Errorf("methods type-safe: %w", err)
+	// passes write distinct files, but names of inline structs depend on
+	// order of registration, so passes collecting them run sequentially
+	errs := make([]error, len(passes))
+	var wg sync.WaitGroup
+	for i, pass := range passes {
+		if g.inline != nil {
+			errs[i] = pass.fn()
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, fn func() error) {
+			defer wg.Done()
+			errs[i] = fn()
+		}(i, pass.fn)
 	}
+	wg.Wait()
 
-	err = g.generateBuilders()
-	if err != nil {
-		return fmt.Errorf("builders: %w", err)
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		if passes[i].name == "" {
+			return err
+		}
+		return fmt.Errorf("%s: %w", passes[i].name, err)
 	}
 
-	err = g.generateRequests()
+	err = g.generateInlineStructs()
 	if err != nil {
-		return fmt.Errorf("requests: %w", err)
+		return fmt.Errorf("inline structs: %w", err)
+	}
+
+	if g.ifChanged {
+		if err := g.generateSchemaHash(hash); err != nil {
+			return fmt.Errorf("schema hash: %w", err)
+		}
 	}
 
+	if g.dryRun != nil {
+		return g.dryRun.report()
+	}
 	return
 }
 
-func (g Generator) writeSource(name string, b *bytes.Buffer) error {
-	if g.nofmt {
-		return ioutil.WriteFile(name, b.Bytes(), 0677)
+// parsedSchemas caches methods and responses parsed once for all
+// generation passes.
+type parsedSchemas struct {
+	methodsOnce sync.Once
+	methods     []schema.MethodDefinition
+	methodsErr  error
+
+	responsesOnce sync.Once
+	responses     []schema.ResponseDefinition
+	responsesErr  error
+
+	valueRefsOnce sync.Once
+	valueRefs     map[string]map[string]bool
+
+	validatedOnce sync.Once
+	validated     map[string]bool
+}
+
+// parseMethods returns methods parsed from methods schema.
+func (g Generator) parseMethods(methodsSchema []byte) ([]schema.MethodDefinition, error) {
+	g.parsed.methodsOnce.Do(func() {
+		g.parsed.methods, g.parsed.methodsErr = g.parser.ParseMethods(methodsSchema)
+	})
+	return g.parsed.methods, g.parsed.methodsErr
+}
+
+// parseResponses returns responses parsed from responses schema.
+func (g Generator) parseResponses(responsesSchema []byte) ([]schema.ResponseDefinition, error) {
+	g.parsed.responsesOnce.Do(func() {
+		g.parsed.responses, g.parsed.responsesErr = g.parser.ParseResponses(responsesSchema)
+	})
+	return g.parsed.responses, g.parsed.responsesErr
+}
+
+// valueRefs returns names of objects which every object contains by
+// value: required references, fields of inline structs and allOf and
+// aliased types. Slices, maps, optional references and oneOf branches
+// don't make types recursive.
+func (g Generator) valueRefs() map[string]map[string]bool {
+	g.parsed.valueRefsOnce.Do(func() {
+		// schema errors are reported by generation of objects
+		objectsSchema, err := g.readSchema(schema.ObjectsSchema)
+		if err != nil {
+			return
+		}
+		objects, err := g.parser.ParseObjects(objectsSchema)
+		if err != nil {
+			return
+		}
+		refs := make(map[string]map[string]bool, len(objects))
+		for _, obj := range objects {
+			refs[obj.Name] = make(map[string]bool)
+			if err := g.collectValueRefs(obj.Expr, true, refs[obj.Name]); err != nil {
+				return
+			}
+		}
+		g.parsed.valueRefs = refs
+	})
+	return g.parsed.valueRefs
+}
+
+// collectValueRefs adds names of objects contained by value in expr to
+// refs. Optional references are pointers only in top-level structs and
+// only in pointer mode of optional fields.
+func (g Generator) collectValueRefs(expr schema.ObjectExpr, top bool, refs map[string]bool) error {
+	switch {
+	case expr.IsReference:
+		ref, err := expr.Ref()
+		if err != nil {
+			return g.schemaErr(expr, err)
+		}
+		refs[ref.Name] = true
+	case expr.IsAllOf:
+		if singleRefAllOf(expr) {
+			return g.collectValueRefs(expr.AllOf[0], false, refs)
+		}
+		allofFields, err := g.allofExtractFields(expr)
+		if err != nil {
+			return err
+		}
+		for _, fields := range allofFields {
+			equal, err := equalExprs(fields)
+			if err != nil {
+				return err
+			}
+			// differing fields are json.RawMessage
+			if equal && len(fields) > 0 {
+				if err := g.collectValueRefs(fields[0], false, refs); err != nil {
+					return err
+				}
+			}
+		}
+	case expr.IsOneOf, expr.ArrayOf != nil, expr.AdditionalProperties != nil, len(expr.PatternProperties) > 0:
+	default:
+		required := make(map[string]bool, len(expr.Required))
+		for _, name := range expr.Required {
+			required[name] = true
+		}
+		for _, prop := range expr.Properties {
+			if top && g.optionalMode == OptionalPointer && prop.Expr.IsReference && len(required) > 0 && !required[prop.Name] {
+				continue
+			}
+			if err := g.collectValueRefs(prop.Expr, false, refs); err != nil {
+				return err
+			}
+		}
 	}
+	return nil
+}
+
+// validated returns names of struct objects reachable from method
+// parameters which implement validate(depth): objects with constrained
+// fields or fields containing such objects. Structs changed by rules are
+// not validated, since rules may replace or remove their fields.
+func (g Generator) validated() map[string]bool {
+	g.parsed.validatedOnce.Do(func() {
+		// schema errors are reported by generation of objects and requests
+		objectsSchema, err := g.readSchema(schema.ObjectsSchema)
+		if err != nil {
+			return
+		}
+		objects, err := g.parser.ParseObjects(objectsSchema)
+		if err != nil {
+			return
+		}
+		methodsSchema, err := g.readSchema(schema.MethodsSchema)
+		if err != nil {
+			return
+		}
+		methods, err := g.parseMethods(methodsSchema)
+		if err != nil {
+			return
+		}
+
+		structs := make(map[string]schema.ObjectDefinition)
+		for _, obj := range objects {
+			expr := obj.Expr
+			if expr.IsBaseType || expr.IsReference || expr.IsEnum || expr.IsAllOf || expr.IsOneOf ||
+				len(expr.Properties) == 0 || len(g.rules["objects.gen.go"][g.objectName(obj.Name)]) > 0 {
+				continue
+			}
+			structs[obj.Name] = obj
+		}
+
+		reachable := make(map[string]bool)
+		var visit func(expr schema.ObjectExpr)
+		visit = func(expr schema.ObjectExpr) {
+			name, ok := nestedRef(expr)
+			if !ok || reachable[name] {
+				return
+			}
+			obj, ok := structs[name]
+			if !ok {
+				return
+			}
+			reachable[name] = true
+			for _, prop := range obj.Expr.Properties {
+				visit(prop.Expr)
+			}
+		}
+		for _, method := range methods {
+			for _, param := range method.Parameters {
+				visit(param.ObjectExpr)
+			}
+		}
+
+		validated := make(map[string]bool)
+		for name := range reachable {
+			obj := structs[name]
+			for _, prop := range obj.Expr.Properties {
+				if checks, _ := g.propertyChecks(g.objectName(name), prop, "obj.field"); checks != "" {
+					validated[name] = true
+					break
+				}
+			}
+		}
+		// containing objects are validated if any of nested ones is
+		for changed := true; changed; {
+			changed = false
+			for name := range reachable {
+				if validated[name] {
+					continue
+				}
+				for _, prop := range structs[name].Expr.Properties {
+					if ref, ok := nestedRef(prop.Expr); ok && validated[ref] {
+						validated[name] = true
+						changed = true
+						break
+					}
+				}
+			}
+		}
+		g.parsed.validated = validated
+	})
+	return g.parsed.validated
+}
 
-	src, err := format.Source(b.Bytes())
+// nestedRef returns name of object referenced by expr directly or by
+// elements of (nested) arrays.
+func nestedRef(expr schema.ObjectExpr) (string, bool) {
+	for expr.ArrayOf != nil {
+		expr = *expr.ArrayOf
+	}
+	if !expr.IsReference {
+		return "", false
+	}
+	ref, err := expr.Ref()
 	if err != nil {
+		return "", false
+	}
+	return ref.Name, true
+}
+
+// refersBack reports whether object ref contains object name by value,
+// directly or through other objects, so field of type ref in name must be
+// pointer to avoid type of infinite size.
+func (g Generator) refersBack(ref, name string) bool {
+	if ref == name {
+		return true
+	}
+
+	valueRefs := g.valueRefs()
+	visited := map[string]bool{ref: true}
+	queue := []string{ref}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for next := range valueRefs[cur] {
+			if next == name {
+				return true
+			}
+			if !visited[next] {
+				visited[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return false
+}
+
+func (g Generator) writeSource(name string, b *bytes.Buffer) error {
+	src := b.Bytes()
+	if structs := g.rules[filepath.Base(name)]; len(structs) > 0 {
+		var err error
+		src, err = patchSource(src, structs)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	if !g.nofmt {
+		var err error
+		src, err = format.Source(src)
+		if err != nil {
+			return err
+		}
+	}
+
+	if g.dryRun != nil {
+		return g.dryRun.compare(name, src)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name, src, 0644)
+}
+
+// dryRunFiles collects generated files instead of writing them and reports
+// which of them differ from files on disk.
+type dryRunFiles struct {
+	mu      sync.Mutex
+	changed map[string]bool
+}
+
+func (d *dryRunFiles) compare(name string, src []byte) error {
+	existing, err := ioutil.ReadFile(name)
+	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
 
-	return ioutil.WriteFile(name, src, 0677)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.changed[name] = err != nil || !bytes.Equal(existing, src)
+	return nil
+}
+
+// report prints status of generated files and returns error if any of them
+// would change.
+func (d *dryRunFiles) report() error {
+	var names []string
+	for name := range d.changed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	changed := 0
+	for _, name := range names {
+		if d.changed[name] {
+			fmt.Println(name + ": would change")
+			changed++
+			continue
+		}
+		fmt.Println(name + ": up to date")
+	}
+	if changed > 0 {
+		return fmt.Errorf("%d generated files would change", changed)
+	}
+	return nil
+}
+
+// readSchema reads schema of the type from its configured path or URL.
+func (g Generator) readSchema(schemaType schema.SchemaType) ([]byte, error) {
+	path, ok := g.schemaPaths[schemaType]
+	if !ok {
+		path = string(schemaType)
+	}
+	return g.source.Read(path)
 }
 
 type callback = func(b *bytes.Buffer, schema []byte) error
 
-func (g Generator) generate(schemaFile, outputName string, cb callback) error {
-	sch, err := ioutil.ReadFile(schemaFile)
+func (g Generator) generate(schemaType schema.SchemaType, outputName string, cb callback) error {
+	sch, err := g.readSchema(schemaType)
 	if err != nil {
 		return err
 	}
 
 	b := bytes.NewBuffer(nil)
-	b.WriteString(genPrefix + "\n\npackage " + pkgName + "\n")
+	b.WriteString(genPrefix + "\n")
+	if g.stamp {
+		b.WriteString(g.stampHeader(schemaType, sch))
+	}
+	b.WriteString("\npackage " + pkgName + "\n")
 
 	err = cb(b, sch)
 	if err != nil {
-		return err
+		return fmt.Errorf("%s: %w", schemaType, err)
 	}
 
 	return g.writeSource(outputName, b)
 }
 
+// stampHeader returns comment with version of schema, if schema has one,
+// and go:generate directive reproducing the run in objects file.
+// Directive changes to parent directory of generated package, since
+// output and schema paths are relative to it.
+func (g Generator) stampHeader(schemaType schema.SchemaType, sch []byte) string {
+	var sb strings.Builder
+	if version := gjson.GetBytes(sch, "version"); version.Exists() {
+		sb.WriteString("// VK schema version " + version.String() + ".\n")
+	}
+	if schemaType == schema.ObjectsSchema && g.command != "" {
+		sb.WriteString("\n//go:generate sh -c " + strconv.Quote("cd .. && "+g.command) + "\n")
+	}
+	return sb.String()
+}
+
+// schemaErr returns err about unsupported schema construct, offending
+// expression is logged in debug mode.
+func (g Generator) schemaErr(expr schema.ObjectExpr, err error) error {
+	if g.debug {
+		log.Printf("%+v", expr)
+	}
+	return err
+}
+
+// selectorImports maps package selectors used by generated code to import paths.
+var selectorImports = map[*regexp.Regexp]string{
+	regexp.MustCompile(`\bstrconv\.`): "strconv",
+	regexp.MustCompile(`\bjson\.`):    "encoding/json",
+	regexp.MustCompile(`\bfmt\.`):     "fmt",
+	// descriptions may end with "bytes."
+	regexp.MustCompile(`\bbytes\.[A-Z]`): "bytes",
+	// descriptions often end with "time."
+	regexp.MustCompile(`\btime\.[A-Z]`): "time",
+}
+
+// writeImports writes imports of packages used by src.
+func writeImports(b *bytes.Buffer, src string) {
+	var paths []string
+	for selector, path := range selectorImports {
+		if selector.MatchString(src) {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return
+	}
+	sort.Strings(paths)
+
+	b.WriteString("\nimport (\n")
+	for _, path := range paths {
+		b.WriteString("\t\"" + path + "\"\n")
+	}
+	b.WriteString(")\n\n")
+}
+
 func (g Generator) generateObjects() error {
-	return g.generate("objects.json", pkgName+"/objects.gen.go",
+	return g.generate(schema.ObjectsSchema, pkgName+"/objects.gen.go",
 		func(b *bytes.Buffer, objectsSchema []byte) error {
 			objects, err := g.parser.ParseObjects(objectsSchema)
 			if err != nil {
 				return err
 			}
+
+			var sb strings.Builder
+			sb.WriteString(g.receiverNote())
+			sb.WriteString(g.interfaces.declarations())
+			sb.WriteString(g.bitmasks.declarations())
 			for _, object := range objects {
-				b.WriteString(g.ObjectDefinitionToGolang(object) + "\n")
+				typ, err := g.ObjectDefinitionToGolang(object)
+				if err != nil {
+					return err
+				}
+				sb.WriteString(typ + "\n")
+			}
+			if g.deepCopy {
+				methods, err := g.deepCopyMethods("objects.gen.go", sb.String())
+				if err != nil {
+					return err
+				}
+				sb.WriteString(methods)
 			}
+			writeImports(b, sb.String())
+			b.WriteString(sb.String())
 
 			return nil
 		})
 }
 
 func (g Generator) generateResponses() error {
-	return g.generate("responses.json", pkgName+"/responses.gen.go",
+	return g.generate(schema.ResponsesSchema, pkgName+"/responses.gen.go",
 		func(b *bytes.Buffer, responsesSchema []byte) error {
-			responses, err := g.parser.ParseResponses(responsesSchema)
+			responses, err := g.parseResponses(responsesSchema)
 			if err != nil {
 				return err
 			}
 
+			var sb strings.Builder
+			sb.WriteString(g.receiverNote())
 			for _, response := range responses {
-				typ := g.ResponseDefinitionToGolang(response)
-				b.WriteString(typ + "\n")
+				typ, err := g.ResponseDefinitionToGolang(response)
+				if err != nil {
+					return err
+				}
+				sb.WriteString(typ + "\n")
+			}
+			if g.summaries {
+				sb.WriteString(summaryStringFunc)
 			}
+			writeImports(b, sb.String())
+			b.WriteString(sb.String())
 			return nil
 		})
 }
 
 func (g Generator) generateMethods() error {
-	return g.generate("methods.json", pkgName+"/methods.gen.go",
+	if g.split != "" {
+		return g.generateSplitMethods()
+	}
+	return g.generate(schema.MethodsSchema, pkgName+"/methods.gen.go",
 		func(b *bytes.Buffer, methodsSchema []byte) error {
-			methods, err := g.parser.ParseMethods(methodsSchema)
+			if g.context {
+				b.WriteString("\nimport \"context\"\n\n")
+			}
+			methods, err := g.parseMethods(methodsSchema)
 			if err != nil {
 				return err
 			}
 
-			for _, method := range methods {
-				for _, response := range method.Responses {
-					extended := strings.Contains(strings.ToLower(response.Name), "extended")
-					if method.Description != nil {
-						b.WriteString("// " + *method.Description + "\n")
-					}
-					methodPostfix := g.goify(response.Name)
-					if len(method.Responses) == 1 || response.Name == "response" {
-						methodPostfix = ""
-					}
-					if strings.HasSuffix(response.Name, "Response") {
-						repl := strings.ReplaceAll(response.Name, "Response", "")
-						if repl != "" {
-							methodPostfix = g.goify(repl)
-						}
-					}
-
-					gresponse := g.objectExprToGolang(response.Expr)
-					if gresponse == "StorageGetWithKeysResponse" {
-						methodPostfix = "With" + methodPostfix
-					}
-					b.WriteString("func (vk *VK) " + g.goify(method.Name) + methodPostfix + "(params Params) (response " + gresponse + ", err error) {\n")
-					if extended {
-						b.WriteString("\tparams[\"extended\"] = true\n")
-					}
-					b.WriteString("\terr = vk.RequestUnmarshal(\"" + method.Name + "\", params, &response)\n")
-					b.WriteString("\treturn\n")
-					b.WriteString("}")
-					b.WriteString("\n\n")
-				}
-			}
-			return nil
-		})
-}
-
-func (g Generator) generateMethodsTypeSafe() error {
-	return g.generate("methods.json", pkgName+"/methods_safe.gen.go",
-		func(b *bytes.Buffer, methodsSchema []byte) error {
-			methods, err := g.parser.ParseMethods(methodsSchema)
+			responses, err := g.responseDefinitions()
 			if err != nil {
 				return err
 			}
 
+			b.WriteString("\n")
 			for _, method := range methods {
 				for _, response := range method.Responses {
-					extended := strings.Contains(strings.ToLower(response.Name), "extended")
-					if method.Description != nil {
-						b.WriteString("// " + *method.Description + "\n")
-					}
-					methodPostfix := g.goify(response.Name)
-					if len(method.Responses) == 1 || response.Name == "response" {
-						methodPostfix = ""
-					}
-					if strings.HasSuffix(response.Name, "Response") {
-						repl := strings.ReplaceAll(response.Name, "Response", "")
-						if repl != "" {
-							methodPostfix = g.goify(repl)
-						}
-					}
-					gresponse := g.objectExprToGolang(response.Expr)
-					if gresponse == "StorageGetWithKeysResponse" {
-						methodPostfix = "With" + methodPostfix
+					extended, methodPostfix, gresponse, err := g.methodVariant(method, response)
+					if err != nil {
+						return err
 					}
-					b.WriteString("func (vk *VK) " + g.goify(method.Name) + methodPostfix + "Safe(req " + g.goify(method.Name) + ") (response " + gresponse + ", err error) {\n")
+					b.WriteString(g.methodDoc(method))
+					b.WriteString("func (vk *VK) " + g.goify(method.Name) + methodPostfix + "(" + g.contextParam() + "params Params) (response " + gresponse + ", err error) {\n")
 					if extended {
-						b.WriteString("\tparams := req.params()\n")
-						b.WriteString("\tparams[\"extended\"] = true\n")
-						b.WriteString("\terr = vk.RequestUnmarshal(\"" + method.Name + "\", params, &response)\n")
-					} else {
-						b.WriteString("\terr = vk.RequestUnmarshal(\"" + method.Name + "\", req.params(), &response)\n")
+						b.WriteString("\tparams[paramExtended] = true\n")
 					}
-
-					b.WriteString("\treturn\n")
+					b.WriteString(g.methodCall(method, "params", ""))
 					b.WriteString("}")
 					b.WriteString("\n\n")
+
+					if resp, ok := responses[response.Definition]; ok {
+						chunked, err := g.chunkedMethod(method, g.goify(method.Name)+methodPostfix, gresponse, resp)
+						if err != nil {
+							return err
+						}
+						b.WriteString(chunked)
+					}
 				}
 			}
 			return nil
 		})
 }
 
-func (g Generator) generateBuilders() error {
-	return g.generate("methods.json", pkgName+"/builders.gen.go",
+// generateSplitMethods generates methods of every VK namespace in its own
+// package as functions taking client, e.g. users.Get(vk, params). Types
+// are qualified with generated package. Chunked methods are not generated.
+func (g Generator) generateSplitMethods() error {
+	methodsSchema, err := g.readSchema(schema.MethodsSchema)
+	if err != nil {
+		return err
+	}
+	methods, err := g.parseMethods(methodsSchema)
+	if err != nil {
+		return err
+	}
+
+	var namespaces []string
+	byNamespace := make(map[string][]schema.MethodDefinition)
+	for _, method := range methods {
+		namespace := strings.ToLower(strings.SplitN(method.Name, ".", 2)[0])
+		if _, ok := byNamespace[namespace]; !ok {
+			namespaces = append(namespaces, namespace)
+		}
+		byNamespace[namespace] = append(byNamespace[namespace], method)
+	}
+
+	sel := importName(g.split)
+	for _, namespace := range namespaces {
+		var body strings.Builder
+		needExtended := false
+		for _, method := range byNamespace[namespace] {
+			name := g.goify(strings.SplitN(method.Name, ".", 2)[1])
+			for _, response := range method.Responses {
+				extended, methodPostfix, gresponse, err := g.methodVariant(method, response)
+				if err != nil {
+					return err
+				}
+				if !isBuiltin(gresponse) {
+					gresponse = sel + "." + gresponse
+				}
+				body.WriteString(g.methodDoc(method))
+				body.WriteString("func " + name + methodPostfix + "(" + g.contextParam() + "vk *" + sel + ".VK, params " + sel + ".Params) (response " + gresponse + ", err error) {\n")
+				if extended {
+					needExtended = true
+					body.WriteString("\tparams[paramExtended] = true\n")
+				}
+				body.WriteString(g.methodCall(method, "params", sel+"."))
+				body.WriteString("}\n\n")
+			}
+		}
+
+		b := bytes.NewBuffer(nil)
+		b.WriteString(genPrefix + "\n\npackage " + namespace + "\n\n")
+		b.WriteString("import (\n")
+		if g.context {
+			b.WriteString("\t\"context\"\n\n")
+		}
+		b.WriteString("\t" + importSpec(g.split) + "\n")
+		b.WriteString(")\n\n")
+		if needExtended {
+			b.WriteString("// paramExtended requests extended responses of methods.\n")
+			b.WriteString("const paramExtended = \"extended\"\n\n")
+		}
+		b.WriteString(body.String())
+		if err := g.writeSource(pkgName+"/"+namespace+"/methods.gen.go", b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// methodDoc returns doc comment of generated method: schema description
+// and deprecation notice.
+func (g Generator) methodDoc(method schema.MethodDefinition) string {
+	var sb strings.Builder
+	if desc := g.comment(method.Description); desc != nil {
+		sb.WriteString(g.docComment("", *desc))
+	}
+	if method.Deprecated {
+		sb.WriteString(deprecatedComment("", method.DeprecatedMessage, g.comment(method.Description) != nil))
+	}
+	return sb.String()
+}
+
+// methodCall returns statements of generated method sending params and
+// returning response, prefix qualifies WrapError outside generated package.
+func (g Generator) methodCall(method schema.MethodDefinition, params, prefix string) string {
+	return "\terr = " + g.requestUnmarshalCall() + "\"" + method.Name + "\", " + params + ", &response)\n" +
+		g.wrapErrorCall(prefix, method.Name) +
+		"\treturn\n"
+}
+
+// methodVariant returns whether method response is extended, postfix of
+// generated method name and Go response type.
+func (g Generator) methodVariant(method schema.MethodDefinition, response schema.MethodResponse) (extended bool, postfix, gresponse string, err error) {
+	extended = strings.Contains(strings.ToLower(response.Variant), "extended")
+	if rule, ok := postfixRules[method.Name+":"+response.Name]; ok {
+		postfix = rule
+	} else if response.Variant != "" {
+		postfix = g.goify(response.Variant)
+	}
+	gresponse, err = g.methodResponseType(response)
+	return extended, postfix, gresponse, err
+}
+
+// postfixRules override postfixes of method names of response variants,
+// keys are method name and response name, e.g. "storage.get:keysResponse".
+// Variants clashing with other methods are renamed by schema parser too,
+// but storage.get keeps its name without storage.getKeys in schema.
+var postfixRules = map[string]string{
+	"storage.get:keysResponse": "WithKeys",
+}
+
+// methodResponseType returns Go type of method response, which is named
+// after referenced response definition. References to missing definitions
+// are errors.
+func (g Generator) methodResponseType(response schema.MethodResponse) (string, error) {
+	if response.Definition != "" {
+		if _, err := response.Expr.Ref(); err != nil {
+			return "", err
+		}
+		return g.responseName(response.Definition), nil
+	}
+	return g.objectExprToGolang(response.Expr)
+}
+
+// builderParamLists generates RequiredParams and OptionalParams methods of
+// builder, which return names of parameters of method in schema order.
+func builderParamLists(method schema.MethodDefinition, builderName string) string {
+	var required, optional []string
+	for _, param := range method.Parameters {
+		if param.Required {
+			required = append(required, strconv.Quote(param.Name))
+		} else {
+			optional = append(optional, strconv.Quote(param.Name))
+		}
+	}
+
+	var sb strings.Builder
+	for _, list := range []struct {
+		fn, kind string
+		names    []string
+	}{
+		{"RequiredParams", "required", required},
+		{"OptionalParams", "optional", optional},
+	} {
+		sb.WriteString("// " + list.fn + " returns names of " + list.kind + " parameters of " + method.Name + ".\n")
+		sb.WriteString("func (" + builderName + ") " + list.fn + "() []string {\n")
+		if len(list.names) == 0 {
+			sb.WriteString("\treturn nil\n")
+		} else {
+			sb.WriteString("\treturn []string{" + strings.Join(list.names, ", ") + "}\n")
+		}
+		sb.WriteString("}\n\n")
+	}
+	return sb.String()
+}
+
+// hasExtendedResponse reports whether method has extended response variant.
+func hasExtendedResponse(method schema.MethodDefinition) bool {
+	for _, response := range method.Responses {
+		if strings.Contains(strings.ToLower(response.Variant), "extended") {
+			return true
+		}
+	}
+	return false
+}
+
+// hasParameter reports whether method has parameter with name.
+func hasParameter(method schema.MethodDefinition, name string) bool {
+	for _, param := range method.Parameters {
+		if param.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// paramsResults returns results of request params() method, which
+// reports missing required parameters unless params are lenient.
+func (g Generator) paramsResults() string {
+	if g.lenientParams {
+		return "Params"
+	}
+	return "(Params, error)"
+}
+
+// requiredParams returns names of method parameters checked by params().
+// Boolean parameters are not checked as false is their valid value.
+func (g Generator) requiredParams(method schema.MethodDefinition) (map[string]struct{}, error) {
+	required := make(map[string]struct{})
+	if g.lenientParams {
+		return required, nil
+	}
+	for _, param := range method.Parameters {
+		if !param.Required {
+			continue
+		}
+		typ, err := g.paramExprToGolang(param.ObjectExpr)
+		if err != nil {
+			return nil, err
+		}
+		if typ != "bool" {
+			required[param.Name] = struct{}{}
+		}
+	}
+	return required, nil
+}
+
+// paramConditions returns conditions reporting whether request field of
+// the type is set or has zero value.
+func paramConditions(field, typ string) (isSet, isZero string) {
+	switch {
+	case strings.HasPrefix(typ, "[]"):
+		return "len(" + field + ") > 0", "len(" + field + ") == 0"
+	case typ == "bool":
+		return field, "!" + field
+	case typ == "string":
+		return field + " != \"\"", field + " == \"\""
+	case typ == "int" || typ == "int32" || typ == "int64" || typ == "float64":
+		return field + " != 0", field + " == 0"
+	}
+	return field + " != nil", field + " == nil"
+}
+
+// accessTokenParam reports whether per-call access token is added to
+// request type and builder of the method.
+func (g Generator) accessTokenParam(method schema.MethodDefinition) bool {
+	return g.perCallToken && !hasParameter(method, "access_token")
+}
+
+// contextParam returns context parameter of generated methods in context mode.
+func (g Generator) contextParam() string {
+	if g.context {
+		return "ctx context.Context, "
+	}
+	return ""
+}
+
+// requestUnmarshalCall returns beginning of the request call with context
+// argument in context mode.
+func (g Generator) requestUnmarshalCall() string {
+	if g.context {
+		return "vk.RequestUnmarshalContext(ctx, "
+	}
+	return "vk.RequestUnmarshal("
+}
+
+// wrapErrorCall returns statement which wraps error of method call into
+// VKError in wrap errors mode. Prefix qualifies WrapError.
+func (g Generator) wrapErrorCall(prefix, method string) string {
+	if !g.wrapErrors {
+		return ""
+	}
+	return "\terr = " + prefix + "WrapError(\"" + method + "\", err)\n"
+}
+
+// receiver returns receiver of method of struct gname. JSON (un)marshalers
+// don't use it: encoding/json calls MarshalJSON of non-addressable values
+// only if it has value receiver and UnmarshalJSON modifies receiver.
+func (g Generator) receiver(name, gname string) string {
+	if g.receiverKind == ReceiverValue {
+		return name + " " + gname
+	}
+	return name + " *" + gname
+}
+
+// receiverNote returns comment on tradeoff of receiver kind of struct
+// methods. It is empty if no such methods are generated.
+func (g Generator) receiverNote() string {
+	if g.noComments || !g.getters && !g.summaries && !g.extendedMerge && len(g.interfaces) == 0 {
+		return ""
+	}
+	if g.receiverKind == ReceiverValue {
+		return "// Methods of structs have value receivers: values and pointers implement\n" +
+			"// interfaces, but structs are copied on every call.\n\n"
+	}
+	return "// Methods of structs have pointer receivers: large structs are not copied\n" +
+		"// on calls, but only pointers implement interfaces.\n\n"
+}
+
+// responseDefinitions returns response definitions by schema name.
+func (g Generator) responseDefinitions() (map[string]schema.ResponseDefinition, error) {
+	sch, err := g.readSchema(schema.ResponsesSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	responses, err := g.parseResponses(sch)
+	if err != nil {
+		return nil, err
+	}
+
+	defs := make(map[string]schema.ResponseDefinition, len(responses))
+	for _, resp := range responses {
+		defs[resp.Name] = resp
+	}
+	return defs, nil
+}
+
+// chunkedMethod generates method which splits integer array parameter
+// limited by maxItems into chunks and merges responses of the chunks.
+// Responses must be arrays or objects with array properties.
+func (g Generator) chunkedMethod(method schema.MethodDefinition, gmethod, gresponse string, resp schema.ResponseDefinition) (string, error) {
+	var chunked []schema.MethodParam
+	for _, param := range method.Parameters {
+		if param.ArrayOf != nil && param.MaxItems != nil && param.ArrayOf.Type == "integer" {
+			chunked = append(chunked, param)
+		}
+	}
+	if len(chunked) != 1 {
+		return "", nil
+	}
+	param := chunked[0]
+	limit := strconv.FormatInt(*param.MaxItems, 10)
+
+	var merge strings.Builder
+	switch {
+	case resp.Expr.ArrayOf != nil:
+		merge.WriteString("\t\tresponse = append(response, chunk...)\n")
+	case len(resp.Expr.Properties) > 0:
+		for _, prop := range resp.Expr.Properties {
+			field := g.goify(prop.Name)
+			if prop.Expr.ArrayOf != nil {
+				merge.WriteString("\t\tresponse." + field + " = append(response." + field + ", chunk." + field + "...)\n")
+			} else if prop.Name == "count" && prop.Expr.Type == "integer" && !g.jsonNumber {
+				merge.WriteString("\t\tresponse." + field + " += chunk." + field + "\n")
+			}
+		}
+	}
+	if merge.Len() == 0 {
+		return "", nil
+	}
+
+	idType, err := g.paramExprToGolang(*param.ArrayOf)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("// " + gmethod + "Chunked calls " + gmethod + " with " + param.Name + " split\n")
+	sb.WriteString("// into chunks of " + limit + " items and merges the results.\n")
+	sb.WriteString("func (vk *VK) " + gmethod + "Chunked(" + g.contextParam() + "ids []" + idType + ", params Params) (response " + gresponse + ", err error) {\n")
+	sb.WriteString("\tfor len(ids) > 0 {\n")
+	sb.WriteString("\t\tn := len(ids)\n")
+	sb.WriteString("\t\tif n > " + limit + " {\n")
+	sb.WriteString("\t\t\tn = " + limit + "\n")
+	sb.WriteString("\t\t}\n\n")
+	sb.WriteString("\t\tchunkParams := make(Params, len(params)+1)\n")
+	sb.WriteString("\t\tfor k, v := range params {\n")
+	sb.WriteString("\t\t\tchunkParams[k] = v\n")
+	sb.WriteString("\t\t}\n")
+	sb.WriteString("\t\tchunkParams[\"" + param.Name + "\"] = ids[:n]\n")
+	sb.WriteString("\t\tids = ids[n:]\n\n")
+	sb.WriteString("\t\tvar chunk " + gresponse + "\n")
+	if g.context {
+		sb.WriteString("\t\tchunk, err = vk." + gmethod + "(ctx, chunkParams)\n")
+	} else {
+		sb.WriteString("\t\tchunk, err = vk." + gmethod + "(chunkParams)\n")
+	}
+	sb.WriteString("\t\tif err != nil {\n")
+	sb.WriteString("\t\t\treturn\n")
+	sb.WriteString("\t\t}\n")
+	sb.WriteString(merge.String())
+	sb.WriteString("\t}\n")
+	sb.WriteString("\treturn\n")
+	sb.WriteString("}\n\n")
+	return sb.String(), nil
+}
+
+// generateMethodsTypeSafe generates methods taking request types of
+// requests.gen.go, which check required parameters and build params map.
+func (g Generator) generateMethodsTypeSafe() error {
+	return g.generate(schema.MethodsSchema, pkgName+"/methods_safe.gen.go",
+		func(b *bytes.Buffer, methodsSchema []byte) error {
+			if g.context {
+				b.WriteString("\nimport \"context\"\n\n")
+			}
+			methods, err := g.parseMethods(methodsSchema)
+			if err != nil {
+				return err
+			}
+
+			for _, method := range methods {
+				for _, response := range method.Responses {
+					extended, methodPostfix, gresponse, err := g.methodVariant(method, response)
+					if err != nil {
+						return err
+					}
+					b.WriteString(g.methodDoc(method))
+					b.WriteString("func (vk *VK) " + g.goify(method.Name) + methodPostfix + "Safe(" + g.contextParam() + "req " + g.typeName(method.Name) + ") (response " + gresponse + ", err error) {\n")
+					switch {
+					case !g.lenientParams:
+						b.WriteString("\tparams, err := req.params()\n")
+						b.WriteString("\tif err != nil {\n")
+						b.WriteString("\t\treturn\n")
+						b.WriteString("\t}\n")
+						if extended && !hasParameter(method, "extended") {
+							b.WriteString("\tparams[paramExtended] = true\n")
+						}
+						b.WriteString(g.methodCall(method, "params", ""))
+					case extended && !hasParameter(method, "extended"):
+						b.WriteString("\tparams := req.params()\n")
+						b.WriteString("\tparams[paramExtended] = true\n")
+						b.WriteString(g.methodCall(method, "params", ""))
+					default:
+						b.WriteString(g.methodCall(method, "req.params()", ""))
+					}
+					b.WriteString("}")
+					b.WriteString("\n\n")
+				}
+			}
+			return nil
+		})
+}
+
+// generateRegistry generates map of object types by schema names for
+// decoding payloads into types chosen at runtime.
+func (g Generator) generateRegistry() error {
+	return g.generate(schema.ObjectsSchema, pkgName+"/registry.gen.go",
+		func(b *bytes.Buffer, objectsSchema []byte) error {
+			objects, err := g.parser.ParseObjects(objectsSchema)
+			if err != nil {
+				return err
+			}
+
+			b.WriteString("\nimport \"reflect\"\n\n")
+			b.WriteString("// ObjectTypes maps schema names of objects to their types.\n")
+			b.WriteString("var ObjectTypes = map[string]reflect.Type{\n")
+			for _, object := range objects {
+				b.WriteString("\t\"" + object.Name + "\": reflect.TypeOf((*" + g.objectName(object.Name) + ")(nil)).Elem(),\n")
+			}
+			b.WriteString("}\n")
+			return nil
+		})
+}
+
+// generateMethodNames generates constants of method names for callers of
+// RequestUnmarshal.
+func (g Generator) generateMethodNames() error {
+	return g.generate(schema.MethodsSchema, pkgName+"/methods_names.gen.go",
+		func(b *bytes.Buffer, methodsSchema []byte) error {
+			methods, err := g.parseMethods(methodsSchema)
+			if err != nil {
+				return err
+			}
+
+			b.WriteString("\n// Names of methods.\n")
+			b.WriteString("const (\n")
+			for _, method := range methods {
+				b.WriteString("\tMethod" + g.goify(method.Name) + " = \"" + method.Name + "\"\n")
+			}
+			b.WriteString(")\n")
+			return nil
+		})
+}
+
+// generateMethodResponses generates map of method names to names of
+// response types. Additional responses are keyed by method name with
+// postfix of generated method, e.g. "groups.getExtended".
+func (g Generator) generateMethodResponses() error {
+	return g.generate(schema.MethodsSchema, pkgName+"/methods_responses.gen.go",
 		func(b *bytes.Buffer, methodsSchema []byte) error {
-			b.WriteString("import \"github.com/SevereCloud/vksdk/api\"\n\n")
-			methods, err := g.parser.ParseMethods(methodsSchema)
+			methods, err := g.parseMethods(methodsSchema)
+			if err != nil {
+				return err
+			}
+
+			b.WriteString("\n// MethodResponses maps names of methods to names of response types.\n")
+			b.WriteString("var MethodResponses = map[string]string{\n")
+			for _, method := range methods {
+				for _, response := range method.Responses {
+					_, postfix, gresponse, err := g.methodVariant(method, response)
+					if err != nil {
+						return err
+					}
+					b.WriteString("\t" + strconv.Quote(method.Name+postfix) + ": " + strconv.Quote(gresponse) + ",\n")
+				}
+			}
+			b.WriteString("}\n")
+			return nil
+		})
+}
+
+func (g Generator) generateBuilders() error {
+	return g.generate(schema.MethodsSchema, pkgName+"/builders.gen.go",
+		func(out *bytes.Buffer, methodsSchema []byte) error {
+			methods, err := g.parseMethods(methodsSchema)
 			if err != nil {
 				return err
 			}
 
+			// imports depend on CSV helpers used by setters
+			b := bytes.NewBuffer(nil)
+			sdk := g.sdkSelector()
+			csvHelpers := make(map[string]string)
+			joinStrings, formatInts := false, false
+
 			for _, method := range methods {
 				// define struct
-				builderName := g.goify(method.Name) + `Builder`
+				builderName := g.typeName(method.Name) + `Builder`
 				b.WriteString("// " + builderName + " builder.\n")
 				b.WriteString("// \n")
-				if method.Description != nil {
-					b.WriteString("// " + *method.Description + "\n")
+				if desc := g.comment(method.Description); desc != nil {
+					b.WriteString(g.docComment("", *desc))
 					b.WriteString("// \n")
 				}
 
+				if g.examples {
+					example, err := g.builderExample(method, builderName)
+					if err != nil {
+						return err
+					}
+					b.WriteString(example)
+				}
 				b.WriteString("// https://vk.com/dev/" + method.Name + "\n")
+				if method.Deprecated {
+					b.WriteString(deprecatedComment("", method.DeprecatedMessage, true))
+				}
 				b.WriteString(`type ` + builderName + ` struct {` + "\n")
-				b.WriteString("\tapi.Params\n")
+				b.WriteString("\t" + sdk + ".Params\n")
 				b.WriteString("}\n\n")
 
 				// define constructor
 				b.WriteString("// " + builderName + " func.\n")
 				b.WriteString("func New" + builderName + "() *" + builderName + " {\n")
-				b.WriteString("\treturn &" + builderName + "{api.Params{}}\n")
+				b.WriteString("\treturn &" + builderName + "{" + sdk + ".Params{}}\n")
+				b.WriteString("}\n\n")
+
+				// setters replace values, so copy of params map is enough
+				b.WriteString("// Clone returns copy of builder, setters of the copy do not affect b.\n")
+				b.WriteString("func (b *" + builderName + ") Clone() *" + builderName + " {\n")
+				b.WriteString("\tparams := make(" + sdk + ".Params, len(b.Params))\n")
+				b.WriteString("\tfor k, v := range b.Params {\n")
+				b.WriteString("\t\tparams[k] = v\n")
+				b.WriteString("\t}\n")
+				b.WriteString("\treturn &" + builderName + "{params}\n")
 				b.WriteString("}\n\n")
 
 				for _, parameter := range method.Parameters {
-					if parameter.Description != nil {
-						b.WriteString("// " + *parameter.Description + "\n")
+					if desc := g.comment(parameter.Description); desc != nil {
+						b.WriteString(g.docComment("", *desc))
+					}
+					if parameter.Deprecated {
+						b.WriteString(deprecatedComment("", parameter.DeprecatedMessage, g.comment(parameter.Description) != nil))
 					}
 
-					gparam := g.objectExprToGolang(parameter.ObjectExpr)
+					// element type is qualified by SDK package, then
+					// single-level arrays become variadic and nested ones
+					// get all levels back, e.g. [][]api.BaseBoolInt
+					gparam, err := g.paramExprToGolang(parameter.ObjectExpr)
+					if err != nil {
+						return err
+					}
 					aLevel := strings.Count(gparam, "[]")
 					gparam = strings.ReplaceAll(gparam, "[]", "")
 					_, isBuiltin := builtinTypes[gparam]
 					if !isBuiltin {
-						gparam = "api." + gparam
+						gparam = sdk + "." + gparam
 					}
 					if aLevel == 1 {
 						gparam = "..." + gparam
@@ -286,30 +1528,236 @@ func (g Generator) generateBuilders() error {
 						}
 					}
 					b.WriteString("func (b *" + builderName + ") " + g.goify(parameter.Name) + "(v " + gparam + ") *" + builderName + " {\n")
-					b.WriteString("\tb.Params[\"" + parameter.Name + "\"] = v\n")
+					if gparam == "bool" {
+						// VK expects 1 and 0 for boolean parameters
+						b.WriteString("\tif v {\n")
+						b.WriteString("\t\tb.Params[\"" + parameter.Name + "\"] = 1\n")
+						b.WriteString("\t} else {\n")
+						b.WriteString("\t\tb.Params[\"" + parameter.Name + "\"] = 0\n")
+						b.WriteString("\t}\n")
+					} else if helper, ok := csvJoinHelpers[strings.TrimPrefix(gparam, "...")]; ok && aLevel == 1 {
+						// VK expects comma-separated values for arrays
+						csvHelpers[helper.name] = helper.body
+						b.WriteString("\tb.Params[\"" + parameter.Name + "\"] = " + helper.name + "(v)\n")
+					} else if gparam == "...string" {
+						joinStrings = true
+						b.WriteString("\tb.Params[\"" + parameter.Name + "\"] = strings.Join(v, \",\")\n")
+					} else if elem, ok, err := g.enumArrayElement(parameter.ObjectExpr); err != nil {
+						return err
+					} else if ok && aLevel == 1 {
+						// enums are joined by schema values
+						joinStrings = true
+						b.WriteString("\ts := make([]string, len(v))\n")
+						b.WriteString("\tfor i, e := range v {\n")
+						if elem == "integer" {
+							formatInts = true
+							b.WriteString("\t\ts[i] = strconv.FormatInt(int64(e), 10)\n")
+						} else {
+							b.WriteString("\t\ts[i] = string(e)\n")
+						}
+						b.WriteString("\t}\n")
+						b.WriteString("\tb.Params[\"" + parameter.Name + "\"] = strings.Join(s, \",\")\n")
+					} else {
+						b.WriteString("\tb.Params[\"" + parameter.Name + "\"] = v\n")
+					}
+					b.WriteString("\treturn b\n")
+					b.WriteString("}\n\n")
+				}
+
+				if g.accessTokenParam(method) {
+					b.WriteString("// AccessToken sets access token used instead of client token.\n")
+					b.WriteString("func (b *" + builderName + ") AccessToken(v string) *" + builderName + " {\n")
+					b.WriteString("\tb.Params[\"access_token\"] = v\n")
+					b.WriteString("\treturn b\n")
+					b.WriteString("}\n\n")
+				}
+
+				b.WriteString(builderParamLists(method, builderName))
+
+				if hasExtendedResponse(method) && !hasParameter(method, "extended") {
+					b.WriteString("// Extended requests extended response of Execute.\n")
+					b.WriteString("func (b *" + builderName + ") Extended() *" + builderName + " {\n")
+					b.WriteString("\tb.Params[paramExtended] = true\n")
 					b.WriteString("\treturn b\n")
 					b.WriteString("}\n\n")
 				}
+
+				for _, response := range method.Responses {
+					extended, methodPostfix, gresponse, err := g.methodVariant(method, response)
+					if err != nil {
+						return err
+					}
+
+					// extended variant must not change params of builder,
+					// which may execute other variants afterwards
+					b.WriteString("// Execute" + methodPostfix + " calls " + method.Name + " with builder params.\n")
+					b.WriteString("func (b *" + builderName + ") Execute" + methodPostfix + "(" + g.contextParam() + "vk *VK) (response " + gresponse + ", err error) {\n")
+					params := "Params(b.Params)"
+					if extended {
+						params = "params"
+						b.WriteString("\tparams := make(Params, len(b.Params)+1)\n")
+						b.WriteString("\tfor k, v := range b.Params {\n")
+						b.WriteString("\t\tparams[k] = v\n")
+						b.WriteString("\t}\n")
+						b.WriteString("\tparams[paramExtended] = true\n")
+					}
+					b.WriteString(g.methodCall(method, params, ""))
+					b.WriteString("}\n\n")
+				}
+			}
+
+			var helpers []string
+			for name := range csvHelpers {
+				helpers = append(helpers, name)
 			}
+			sort.Strings(helpers)
+			for _, name := range helpers {
+				b.WriteString(csvHelpers[name])
+			}
+
+			src := b.String()
+			out.WriteString("import (\n")
+			if g.context {
+				out.WriteString("\t\"context\"\n")
+			}
+			if formatInts || len(csvHelpers) > 0 {
+				out.WriteString("\t\"strconv\"\n")
+			}
+			if joinStrings || len(csvHelpers) > 0 {
+				out.WriteString("\t\"strings\"\n")
+			}
+			out.WriteString("\n\t" + importSpec(g.sdkImport) + "\n")
+			out.WriteString(")\n\n")
+			out.WriteString(src)
 			return nil
 		})
 }
 
+// enumArrayElement returns schema type of enum elements of array
+// parameter, which builders join by schema values.
+func (g Generator) enumArrayElement(expr schema.ObjectExpr) (string, bool, error) {
+	if expr.ArrayOf == nil || !expr.ArrayOf.IsReference {
+		return "", false, nil
+	}
+	ref, err := expr.ArrayOf.Ref()
+	if err != nil {
+		return "", false, g.schemaErr(expr, err)
+	}
+	switch {
+	case !ref.Expr.IsEnum:
+		return "", false, nil
+	case ref.Expr.Type == "integer":
+		return "integer", true, nil
+	case ref.Expr.Type == "string" && !g.enumIntBacked:
+		return "string", true, nil
+	}
+	return "", false, nil
+}
+
+// sdkSelector returns package name of SDK used in generated code.
+func (g Generator) sdkSelector() string {
+	return importName(g.sdkImport)
+}
+
+// importName returns package name of import path. Major version suffix of
+// module path is not package name.
+func importName(importPath string) string {
+	name := path.Base(importPath)
+	if majorVersion.MatchString(name) {
+		name = path.Base(path.Dir(importPath))
+	}
+	return name
+}
+
+var majorVersion = regexp.MustCompile(`^v[0-9]+$`)
+
+// importSpec returns import spec of path, named if package name differs
+// from last element of import path.
+func importSpec(importPath string) string {
+	spec := strconv.Quote(importPath)
+	if name := importName(importPath); name != path.Base(importPath) {
+		spec = name + " " + spec
+	}
+	return spec
+}
+
+// csvJoinHelper joins numeric slice into comma-separated values.
+type csvJoinHelper struct {
+	name string
+	body string
+}
+
+// csvJoinHelpers maps element type of builder array setter to helper.
+// Strings are joined with strings.Join.
+var csvJoinHelpers = map[string]csvJoinHelper{
+	"int": {"csvInt", `
+// csvInt joins integers with comma.
+func csvInt(v []int) string {
+	s := make([]string, len(v))
+	for i, n := range v {
+		s[i] = strconv.Itoa(n)
+	}
+	return strings.Join(s, ",")
+}
+`},
+	"int32": {"csvInt32", `
+// csvInt32 joins integers with comma.
+func csvInt32(v []int32) string {
+	s := make([]string, len(v))
+	for i, n := range v {
+		s[i] = strconv.FormatInt(int64(n), 10)
+	}
+	return strings.Join(s, ",")
+}
+`},
+	"int64": {"csvInt64", `
+// csvInt64 joins integers with comma.
+func csvInt64(v []int64) string {
+	s := make([]string, len(v))
+	for i, n := range v {
+		s[i] = strconv.FormatInt(n, 10)
+	}
+	return strings.Join(s, ",")
+}
+`},
+	"float64": {"csvFloat64", `
+// csvFloat64 joins numbers with comma.
+func csvFloat64(v []float64) string {
+	s := make([]string, len(v))
+	for i, n := range v {
+		s[i] = strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	return strings.Join(s, ",")
+}
+`},
+}
+
 func (g Generator) generateRequests() error {
-	return g.generate("methods.json", pkgName+"/requests.gen.go",
-		func(b *bytes.Buffer, methodsSchema []byte) error {
-			methods, err := g.parser.ParseMethods(methodsSchema)
+	return g.generate(schema.MethodsSchema, pkgName+"/requests.gen.go",
+		func(out *bytes.Buffer, methodsSchema []byte) error {
+			methods, err := g.parseMethods(methodsSchema)
 			if err != nil {
 				return err
 			}
 
+			// imports depend on checks of parameters
+			b := bytes.NewBuffer(nil)
+			var needErrors bool
+			needImports := make(map[string]bool)
+
+			applier := g.prefix + "ParamsApplier"
+			b.WriteString("\n// " + applier + " is implemented by all request types.\n")
+			b.WriteString("type " + applier + " interface {\n")
+			b.WriteString("\tparams() " + g.paramsResults() + "\n")
+			b.WriteString("}\n\n")
+
 			for _, method := range methods {
 				// define struct
-				requestName := g.goify(method.Name)
+				requestName := g.typeName(method.Name)
 				b.WriteString("// " + requestName + ".\n")
 				b.WriteString("// \n")
-				if method.Description != nil {
-					b.WriteString("// " + *method.Description + "\n")
+				if desc := g.comment(method.Description); desc != nil {
+					b.WriteString(g.docComment("", *desc))
 					b.WriteString("// \n")
 				}
 
@@ -317,367 +1765,2658 @@ func (g Generator) generateRequests() error {
 				b.WriteString("type " + requestName + " struct{\n")
 				for _, parameter := range method.Parameters {
 					paramName := g.goify(parameter.Name)
-					paramType := g.objectExprToGolang(parameter.ObjectExpr)
+					paramType, err := g.paramExprToGolang(parameter.ObjectExpr)
+					if err != nil {
+						return err
+					}
 					if _, isBuiltin := builtinTypes[paramType]; !isBuiltin && !strings.HasPrefix(paramType, "[]") {
 						paramType = "*" + paramType
 					}
+					above, trailing := g.fieldComment(g.comment(parameter.Description))
+					b.WriteString(above)
 					b.WriteString("\t" + paramName + " " + paramType)
-					if parameter.Description != nil {
-						b.WriteString("// " + *parameter.Description)
+					if tag := g.defaultTag(parameter.ObjectExpr); tag != "" {
+						b.WriteString(" `" + strings.TrimSpace(tag) + "`")
 					}
-					b.WriteString("\n")
+					b.WriteString(trailing + "\n")
+				}
+				if g.accessTokenParam(method) {
+					b.WriteString("\tAccessToken string // Access token used instead of client token if set\n")
 				}
 				b.WriteString("}\n\n")
 
-				b.WriteString("func (req " + requestName + ") params() Params {\n")
+				required, err := g.requiredParams(method)
+				if err != nil {
+					return err
+				}
+				if len(required) > 0 {
+					needErrors = true
+				}
+				b.WriteString("func (req " + requestName + ") params() " + g.paramsResults() + " {\n")
 				b.WriteString("\tparams := make(Params)\n")
 				for _, parameter := range method.Parameters {
 					pname := g.goify(parameter.Name)
-					ptype := g.objectExprToGolang(parameter.ObjectExpr)
-					b.WriteString("\tif ")
-					if strings.HasPrefix(ptype, "[]") {
-						b.WriteString("len(req." + pname + ") > 0")
-					} else if ptype == "bool" {
-						b.WriteString("req." + pname)
-					} else if ptype == "string" {
-						b.WriteString("req." + pname + " != \"\"")
-					} else if ptype == "int64" || ptype == "float64" {
-						b.WriteString("req." + pname + " != 0")
-					} else {
-						b.WriteString("req." + pname + " != nil")
+					ptype, err := g.paramExprToGolang(parameter.ObjectExpr)
+					if err != nil {
+						return err
+					}
+					isSet, isZero := paramConditions("req."+pname, ptype)
+					if _, ok := required[parameter.Name]; ok {
+						b.WriteString("\tif " + isZero + " {\n")
+						b.WriteString("\t\treturn nil, errors.New(\"" + method.Name + ": " + parameter.Name + " is required\")\n")
+						b.WriteString("\t}\n")
+						b.WriteString("\tparams[\"" + parameter.Name + "\"] = req." + pname + "\n")
+						continue
 					}
 
-					b.WriteString(" {\n")
-					b.WriteString("\t\tparams[\"" + parameter.Name + "\"] = req." + g.goify(parameter.Name) + "\n")
+					b.WriteString("\tif " + isSet + " {\n")
+					if ptype == "bool" {
+						b.WriteString("\t\tparams[\"" + parameter.Name + "\"] = 1\n")
+					} else {
+						b.WriteString("\t\tparams[\"" + parameter.Name + "\"] = req." + g.goify(parameter.Name) + "\n")
+					}
 					b.WriteString("\t}\n")
 				}
-				b.WriteString("\treturn params\n")
+				if g.accessTokenParam(method) {
+					b.WriteString("\tif req.AccessToken != \"\" {\n")
+					b.WriteString("\t\tparams[\"access_token\"] = req.AccessToken\n")
+					b.WriteString("\t}\n")
+				}
+				if g.lenientParams {
+					b.WriteString("\treturn params\n")
+				} else {
+					b.WriteString("\treturn params, nil\n")
+				}
 				b.WriteString("}\n\n")
+				b.WriteString("var _ " + applier + " = " + requestName + "{}\n\n")
 
-			}
+				if g.urlValues {
+					b.WriteString(g.toValuesMethod(requestName))
+				}
+
+				if g.reqDefaults {
+					defaults, err := g.requestDefaults(method, requestName)
+					if err != nil {
+						return err
+					}
+					b.WriteString(defaults)
+				}
+
+				validate, validateImports, err := g.validateMethod(method, requestName)
+				if err != nil {
+					return err
+				}
+				for _, path := range validateImports {
+					needImports[path] = true
+				}
+				b.WriteString(validate)
+			}
+
+			var imports []string
+			if needErrors {
+				needImports["errors"] = true
+			}
+			for path := range needImports {
+				imports = append(imports, path)
+			}
+			if g.urlValues {
+				imports = append(imports, "encoding/json", "net/url", "reflect", "strconv", "strings")
+				b.WriteString(paramsValuesFunc)
+			}
+			sort.Strings(imports)
+			switch len(imports) {
+			case 0:
+			case 1:
+				out.WriteString("\nimport \"" + imports[0] + "\"\n")
+			default:
+				out.WriteString("\nimport (\n")
+				for _, path := range imports {
+					out.WriteString("\t\"" + path + "\"\n")
+				}
+				out.WriteString(")\n")
+			}
+			out.Write(b.Bytes())
 			return nil
 		})
 }
 
-func (g Generator) goify(name string) string {
-	if g.nogoify {
-		return name
+// toValuesMethod generates ToValues method of request, which returns
+// parameters as url.Values for direct HTTP requests.
+func (g Generator) toValuesMethod(requestName string) string {
+	var sb strings.Builder
+	sb.WriteString("// ToValues returns parameters of request in VK wire format.\n")
+	if g.lenientParams {
+		sb.WriteString("func (req " + requestName + ") ToValues() url.Values {\n")
+		sb.WriteString("\treturn paramsValues(req.params())\n")
+		sb.WriteString("}\n\n")
+		return sb.String()
 	}
+	sb.WriteString("func (req " + requestName + ") ToValues() (url.Values, error) {\n")
+	sb.WriteString("\tparams, err := req.params()\n")
+	sb.WriteString("\tif err != nil {\n")
+	sb.WriteString("\t\treturn nil, err\n")
+	sb.WriteString("\t}\n")
+	sb.WriteString("\treturn paramsValues(params), nil\n")
+	sb.WriteString("}\n\n")
+	return sb.String()
+}
 
-	runes := []rune(name)
-	runes[0] = unicode.ToUpper(runes[0])
-	for i, r := range runes {
-		if r == '_' || r == ' ' || r == '.' {
-			if i+1 == len(runes) {
-				break
+// paramsValuesFunc converts request parameters to url.Values.
+const paramsValuesFunc = `// paramsValues converts parameters to VK wire format: booleans are 1 or 0,
+// slices are comma-separated, objects are JSON.
+func paramsValues(params Params) url.Values {
+	values := make(url.Values, len(params))
+	for name, v := range params {
+		values.Set(name, formatParam(reflect.ValueOf(v)))
+	}
+	return values
+}
+
+func formatParam(v reflect.Value) string {
+	if !v.IsValid() {
+		return ""
+	}
+	// enums backed by integers marshal schema values
+	if m, ok := v.Interface().(json.Marshaler); ok && v.Kind() != reflect.Ptr {
+		data, err := m.MarshalJSON()
+		if err == nil {
+			var s string
+			if json.Unmarshal(data, &s) == nil {
+				return s
 			}
-			runes[i+1] = unicode.ToUpper(runes[i+1])
+			return string(data)
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return ""
+		}
+		return formatParam(v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			return "1"
+		}
+		return "0"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	case reflect.String:
+		return v.String()
+	case reflect.Slice, reflect.Array:
+		s := make([]string, v.Len())
+		for i := range s {
+			s[i] = formatParam(v.Index(i))
+		}
+		return strings.Join(s, ",")
+	}
+
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+`
+
+// requestDefaults generates constructor of request with parameters set to
+// schema defaults. Zero defaults of values are skipped, since such
+// parameters are not sent anyway. It returns empty string if method has no
+// defaults.
+func (g Generator) requestDefaults(method schema.MethodDefinition, requestName string) (string, error) {
+	var fields, pointers strings.Builder
+	for _, parameter := range method.Parameters {
+		if parameter.Default == nil {
+			continue
+		}
+		pname := g.goify(parameter.Name)
+		ptype, err := g.paramExprToGolang(parameter.ObjectExpr)
+		if err != nil {
+			return "", err
+		}
+		if _, isBuiltin := builtinTypes[ptype]; isBuiltin {
+			if lit, ok := defaultLiteral(ptype, *parameter.Default); ok {
+				fields.WriteString("\t\t" + pname + ": " + lit + ",\n")
+			}
+			continue
+		}
+		if !parameter.IsReference {
+			continue
+		}
+
+		// enums of named types are set through pointers
+		ref, err := parameter.Ref()
+		if err != nil {
+			return "", g.schemaErr(parameter.ObjectExpr, err)
+		}
+		lit, ok := defaultLiteral(ref.Expr.Type, *parameter.Default)
+		if !ok || g.enumIntBacked && ref.Expr.Type == "string" {
+			continue
+		}
+		v := escapeKeyword(lowerFirst(pname))
+		pointers.WriteString("\t" + v + " := " + ptype + "(" + lit + ")\n")
+		pointers.WriteString("\treq." + pname + " = &" + v + "\n")
+	}
+	if fields.Len() == 0 && pointers.Len() == 0 {
+		return "", nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString("// " + requestName + "Defaults returns " + requestName + " with parameters set to schema defaults.\n")
+	sb.WriteString("func " + requestName + "Defaults() " + requestName + " {\n")
+	if pointers.Len() == 0 {
+		sb.WriteString("\treturn " + requestName + "{\n" + fields.String() + "\t}\n")
+		sb.WriteString("}\n\n")
+		return sb.String(), nil
+	}
+	sb.WriteString("\treq := " + requestName + "{\n" + fields.String() + "\t}\n")
+	sb.WriteString(pointers.String())
+	sb.WriteString("\treturn req\n")
+	sb.WriteString("}\n\n")
+	return sb.String(), nil
+}
+
+// builderExample returns doc comment paragraph with chain of builder
+// setters of the first parameters which have sample values. It is empty if
+// there are no such parameters.
+func (g Generator) builderExample(method schema.MethodDefinition, builderName string) (string, error) {
+	const maxSetters = 2
+
+	chain := "New" + builderName + "()"
+	setters := 0
+	for _, param := range method.Parameters {
+		if setters == maxSetters {
+			break
+		}
+		typ, err := g.paramExprToGolang(param.ObjectExpr)
+		if err != nil {
+			return "", err
+		}
+		lit, ok := sampleLiteral(typ, param.ObjectExpr)
+		if !ok {
+			continue
+		}
+		chain += "." + g.goify(param.Name) + "(" + lit + ")"
+		setters++
+	}
+	if setters == 0 {
+		return "", nil
+	}
+	return "// Example:\n// \n//\tparams := " + chain + ".Params\n// \n", nil
+}
+
+// sampleLiteral returns Go literal of example value of parameter with the
+// type: schema default, the first enum value or a value within bounds.
+// Slices, which are variadic parameters of setters, have single element.
+// Nested slices and other types have no samples.
+func sampleLiteral(typ string, expr schema.ObjectExpr) (string, bool) {
+	if strings.HasPrefix(typ, "[]") {
+		elem := strings.TrimPrefix(typ, "[]")
+		if expr.ArrayOf == nil || strings.HasPrefix(elem, "[]") {
+			return "", false
+		}
+		return sampleLiteral(elem, *expr.ArrayOf)
+	}
+
+	if expr.Default != nil {
+		if lit, ok := defaultLiteral(typ, *expr.Default); ok {
+			return lit, true
+		}
+	}
+	if expr.IsEnum && len(expr.Enum) > 0 {
+		switch v := expr.Enum[0].(type) {
+		case string:
+			if typ == "string" {
+				return strconv.Quote(v), true
+			}
+		case int64:
+			if typ == "int" || typ == "int32" || typ == "int64" {
+				return strconv.FormatInt(v, 10), true
+			}
+		case float64:
+			if typ == "float64" {
+				return strconv.FormatFloat(v, 'g', -1, 64), true
+			}
+		}
+		return "", false
+	}
+
+	switch typ {
+	case "string":
+		return `"text"`, true
+	case "int", "int32", "int64", "float64":
+		v := 1.0
+		if expr.Minimum != nil && *expr.Minimum > v {
+			v = *expr.Minimum
+		}
+		if expr.Maximum != nil && *expr.Maximum < v {
+			v = *expr.Maximum
+		}
+		if typ != "float64" {
+			v = math.Ceil(v)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case "bool":
+		return "true", true
+	}
+	return "", false
+}
+
+// defaultLiteral returns Go literal of schema default of the type. Zero
+// values are reported as missing for builtin types.
+func defaultLiteral(typ, def string) (string, bool) {
+	switch typ {
+	case "string":
+		return strconv.Quote(def), def != ""
+	case "int", "int32", "int64", "integer":
+		n, err := strconv.ParseInt(def, 10, 64)
+		return strconv.FormatInt(n, 10), err == nil && (n != 0 || typ == "integer")
+	case "float64", "number":
+		f, err := strconv.ParseFloat(def, 64)
+		return strconv.FormatFloat(f, 'g', -1, 64), err == nil && (f != 0 || typ == "number")
+	case "bool":
+		return "true", def == "1" || def == "true"
+	}
+	return "", false
+}
+
+// validateMethod generates Validate method of request which checks
+// constraints of set parameters: bounds of numbers, lengths of strings and
+// enum values, and constraints of nested objects. Checks are done by
+// validate(depth), so errors of nested values are prefixed by their path.
+// It returns empty string if parameters are not constrained and imports
+// used by the methods.
+func (g Generator) validateMethod(method schema.MethodDefinition, requestName string) (string, []string, error) {
+	var checks strings.Builder
+	imports := []string{"fmt"}
+	needErrors, needUTF8 := false, false
+	validated := g.validated()
+	for _, param := range method.Parameters {
+		field := "req." + g.goify(param.Name)
+		if ref, ok := nestedRef(param.ObjectExpr); ok && validated[ref] {
+			checks.WriteString("\tif err := validateNested(" + strconv.Quote(param.Name) + ", " + field + ", depth); err != nil {\n")
+			checks.WriteString("\t\treturn err\n")
+			checks.WriteString("\t}\n")
+			continue
+		}
+
+		typ, err := g.paramExprToGolang(param.ObjectExpr)
+		if err != nil {
+			return "", nil, err
+		}
+		check, lengths := constraintChecks(field, typ, param.Name, param.ObjectExpr)
+		if check == "" {
+			continue
 		}
+		checks.WriteString(check)
+		needErrors = true
+		needUTF8 = needUTF8 || lengths
+	}
+	if checks.Len() == 0 {
+		return "", nil, nil
+	}
+	if needErrors {
+		imports = append(imports, "errors")
+	}
+	if needUTF8 {
+		imports = append(imports, "unicode/utf8")
 	}
 
-	return g.goifyReplacer.Replace(string(runes))
+	var sb strings.Builder
+	sb.WriteString("// Validate checks constraints of set parameters.\n")
+	sb.WriteString("func (req " + requestName + ") Validate() error {\n")
+	sb.WriteString("\tif err := req.validate(0); err != nil {\n")
+	sb.WriteString("\t\treturn fmt.Errorf(\"" + method.Name + ": %w\", err)\n")
+	sb.WriteString("\t}\n")
+	sb.WriteString("\treturn nil\n")
+	sb.WriteString("}\n\n")
+	sb.WriteString("func (req " + requestName + ") validate(depth int) error {\n")
+	sb.WriteString(checks.String())
+	sb.WriteString("\treturn nil\n")
+	sb.WriteString("}\n\n")
+	return sb.String(), imports, nil
+}
+
+// propertyChecks returns checks of constraints of property of struct gname
+// stored in field. Only properties of numeric and string types are
+// checked. It reports whether lengths of strings are checked.
+func (g Generator) propertyChecks(gname string, prop schema.ObjectDefinition, field string) (string, bool) {
+	switch prop.Expr.Type {
+	case "integer", "number", "string":
+	default:
+		return "", false
+	}
+	if prop.Expr.IsEnum {
+		return "", false
+	}
+	typ, err := g.propertyToGolang(gname, prop)
+	if err != nil {
+		// reported by generation of objects
+		return "", false
+	}
+	return constraintChecks(field, typ, prop.Name, prop.Expr)
 }
 
-func (g Generator) ObjectDefinitionToGolang(obj schema.ObjectDefinition) string {
+// constraintChecks returns checks of bounds, lengths and enum values of
+// field of type typ if it is set. Errors name the value by name. It
+// reports whether lengths of strings are checked.
+func constraintChecks(field, typ, name string, expr schema.ObjectExpr) (string, bool) {
 	var sb strings.Builder
-	if obj.Expr.Description != nil {
-		sb.WriteString("// " + *obj.Expr.Description + "\n")
+	lengths := false
+	fail := func(cond, msg string) {
+		sb.WriteString("\t\tif " + cond + " {\n")
+		sb.WriteString("\t\t\treturn errors.New(" + strconv.Quote(name+" "+msg) + ")\n")
+		sb.WriteString("\t\t}\n")
+	}
+
+	switch typ {
+	case "int", "int32", "int64", "float64":
+		if expr.Minimum != nil {
+			min := strconv.FormatFloat(*expr.Minimum, 'f', -1, 64)
+			fail(field+" < "+min, "must be at least "+min)
+		}
+		if expr.Maximum != nil {
+			max := strconv.FormatFloat(*expr.Maximum, 'f', -1, 64)
+			fail(field+" > "+max, "must be at most "+max)
+		}
+	case "string":
+		if expr.MinLength != nil {
+			min := strconv.FormatInt(*expr.MinLength, 10)
+			fail("utf8.RuneCountInString("+field+") < "+min, "must be at least "+min+" characters")
+			lengths = true
+		}
+		if expr.MaxLength != nil {
+			max := strconv.FormatInt(*expr.MaxLength, 10)
+			fail("utf8.RuneCountInString("+field+") > "+max, "must be at most "+max+" characters")
+			lengths = true
+		}
+	default:
+		return "", false
+	}
+
+	if expr.IsEnum && len(expr.Enum) > 0 {
+		var values []string
+		for _, val := range expr.Enum {
+			switch v := val.(type) {
+			case string:
+				values = append(values, strconv.Quote(v))
+			default:
+				values = append(values, fmt.Sprint(v))
+			}
+		}
+		sb.WriteString("\t\tswitch " + field + " {\n")
+		sb.WriteString("\t\tcase " + strings.Join(values, ", ") + ":\n")
+		sb.WriteString("\t\tdefault:\n")
+		sb.WriteString("\t\t\treturn errors.New(" + strconv.Quote(name+" must be one of "+strings.Join(values, ", ")) + ")\n")
+		sb.WriteString("\t\t}\n")
+	}
+
+	if sb.Len() == 0 {
+		return "", false
+	}
+	isSet, _ := paramConditions(field, typ)
+	return "\tif " + isSet + " {\n" + sb.String() + "\t}\n", lengths
+}
+
+// generateErrors generates typed error codes with predicates of well-known
+// codes. Codes of errors declared by methods and their descriptions are
+// generated only if errors schema is set.
+func (g Generator) generateErrors() error {
+	b := bytes.NewBuffer(nil)
+	b.WriteString(genPrefix + "\n\npackage " + pkgName + "\n\n")
+	// SDK package is aliased, errors is taken by standard package
+	b.WriteString("import (\n")
+	b.WriteString("\t\"errors\"\n\n")
+	b.WriteString("\tvkerrors \"" + g.sdkImport + "/errors\"\n")
+	b.WriteString(")\n\n")
+	b.WriteString("// ErrorCode is code of error returned by method.\n")
+	b.WriteString("type ErrorCode int\n\n")
+	b.WriteString(errorCodePredicates)
+	if g.wrapErrors {
+		b.WriteString(vkErrorType)
+	} else {
+		b.WriteString(sdkErrorCode)
+	}
+
+	if g.schemaPaths[schema.ErrorsSchema] != "" {
+		if err := g.methodErrors(b); err != nil {
+			return err
+		}
+	}
+	return g.writeSource(pkgName+"/errors.gen.go", b)
+}
+
+// errorCodePredicates reports well-known error codes of VK API.
+const errorCodePredicates = `// IsRateLimited reports whether request was rejected by rate limits:
+// too many requests per second, flood control or rate limit reached.
+func IsRateLimited(err error) bool {
+	switch errorCode(err) {
+	case 6, 9, 29:
+		return true
+	}
+	return false
+}
+
+// IsTokenInvalid reports whether user or application authorization
+// failed because of invalid access token.
+func IsTokenInvalid(err error) bool {
+	switch errorCode(err) {
+	case 5, 28:
+		return true
+	}
+	return false
+}
+`
+
+// sdkErrorCode returns code of SDK error when errors are not wrapped.
+const sdkErrorCode = `
+// errorCode returns VK error code of err or errors it wraps, zero if
+// there is no VK error.
+func errorCode(err error) ErrorCode {
+	for ; err != nil; err = errors.Unwrap(err) {
+		if code := vkerrors.GetType(err); code != vkerrors.NoType {
+			return ErrorCode(code)
+		}
+	}
+	return 0
+}
+`
+
+// vkErrorType is error of method call which carries VK error code.
+const vkErrorType = `
+// VKError is error of method call, Code is VK error code or zero if
+// request failed before VK returned error.
+type VKError struct {
+	Method string
+	Code   ErrorCode
+	Err    error
+}
+
+func (e *VKError) Error() string {
+	return e.Method + ": " + e.Err.Error()
+}
+
+func (e *VKError) Unwrap() error {
+	return e.Err
+}
+
+// WrapError wraps error of method call into VKError, nil error is
+// returned as is.
+func WrapError(method string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &VKError{
+		Method: method,
+		Code:   ErrorCode(vkerrors.GetType(err)),
+		Err:    err,
+	}
+}
+
+// errorCode returns VK error code of err, zero if it is not VKError.
+func errorCode(err error) ErrorCode {
+	var vkErr *VKError
+	if errors.As(err, &vkErr) {
+		return vkErr.Code
+	}
+	return 0
+}
+`
+
+// methodErrors writes codes of errors declared by methods and
+// descriptions of the codes.
+func (g Generator) methodErrors(b *bytes.Buffer) error {
+	errorsSchema, err := g.readSchema(schema.ErrorsSchema)
+	if err != nil {
+		return err
+	}
+	errs, err := g.parser.ParseErrors(errorsSchema)
+	if err != nil {
+		return err
+	}
+	defs := make(map[string]schema.ErrorDefinition, len(errs))
+	for _, e := range errs {
+		defs[e.Name] = e
+	}
+
+	methodsSchema, err := g.readSchema(schema.MethodsSchema)
+	if err != nil {
+		return err
+	}
+	methods, err := g.parseMethods(methodsSchema)
+	if err != nil {
+		return err
+	}
+
+	var codes []int64
+	descriptions := make(map[int64]string)
+	for _, method := range methods {
+		if len(method.Errors) == 0 {
+			continue
+		}
+
+		gmethod := g.goify(method.Name)
+		b.WriteString("\n// Errors of " + method.Name + " method.\n")
+		b.WriteString("const (\n")
+		for _, name := range method.Errors {
+			def, ok := defs[name]
+			if !ok {
+				return fmt.Errorf("%s: unknown error %s", method.Name, name)
+			}
+			gname := gmethod + "Error" + g.goify(strings.TrimPrefix(name, "api_error_"))
+			b.WriteString("\t" + gname + " ErrorCode = " + strconv.FormatInt(def.Code, 10) + "\n")
+
+			if _, ok := descriptions[def.Code]; !ok && def.Description != nil {
+				codes = append(codes, def.Code)
+				descriptions[def.Code] = *def.Description
+			}
+		}
+		b.WriteString(")\n")
+	}
+
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	b.WriteString("\n// ErrorDescriptions maps error codes to descriptions.\n")
+	b.WriteString("var ErrorDescriptions = map[ErrorCode]string{\n")
+	for _, code := range codes {
+		b.WriteString("\t" + strconv.FormatInt(code, 10) + ": " + strconv.Quote(descriptions[code]) + ",\n")
+	}
+	b.WriteString("}\n")
+	return nil
+}
+
+// generateClient generates client helpers which do not depend on schema.
+// They are generated in split mode too, so methods, builders and
+// type-safe methods share paramExtended.
+func (g Generator) generateClient() error {
+	b := bytes.NewBuffer(nil)
+	b.WriteString(genPrefix + "\n\npackage " + pkgName + "\n\n")
+	b.WriteString("// paramExtended requests extended responses of methods.\n")
+	b.WriteString("const paramExtended = \"extended\"\n\n")
+	b.WriteString("// WithTokenProvider makes VK request access token from provider for\n")
+	b.WriteString("// every request. It allows tokens rotation and per-request token selection.\n")
+	if !g.perCallToken {
+		b.WriteString("// Tokens set in params are sent as is, client token should be empty.\n")
+	}
+	b.WriteString("func (vk *VK) WithTokenProvider(provider func() string) *VK {\n")
+	b.WriteString("\thandler := vk.Handler\n")
+	b.WriteString("\tvk.Handler = func(method string, params Params) (Response, error) {\n")
+	if g.perCallToken {
+		// per-call token takes precedence over provider, VK fills
+		// client token of requests without one before calling handler
+		b.WriteString("\t\tif token, ok := params[\"access_token\"]; !ok || token == vk.AccessToken {\n")
+		b.WriteString("\t\t\tparams[\"access_token\"] = provider()\n")
+		b.WriteString("\t\t}\n")
+	} else {
+		// explicit token takes precedence over provider, VK fills empty
+		// client token of requests without one
+		b.WriteString("\t\tif token, ok := params[\"access_token\"]; !ok || token == \"\" {\n")
+		b.WriteString("\t\t\tparams[\"access_token\"] = provider()\n")
+		b.WriteString("\t\t}\n")
+	}
+	b.WriteString("\t\treturn handler(method, params)\n")
+	b.WriteString("\t}\n")
+	b.WriteString("\treturn vk\n")
+	b.WriteString("}\n")
+	return g.writeSource(pkgName+"/client.gen.go", b)
+}
+
+// generateSupport generates generic helpers for manual decoding of
+// responses. Generated code requires Go 1.18.
+func (g Generator) generateSupport() error {
+	if !g.generics {
+		return nil
+	}
+
+	b := bytes.NewBuffer(nil)
+	b.WriteString(genPrefix + "\n\npackage " + pkgName + "\n\n")
+	b.WriteString("import (\n")
+	b.WriteString("\t\"encoding/json\"\n\n")
+	b.WriteString("\t\"" + g.sdkImport + "/errors\"\n")
+	b.WriteString(")\n\n")
+	b.WriteString("// UnwrapResponse decodes payload of VK response envelope into T.\n")
+	b.WriteString("// Envelope with error is returned as error.\n")
+	b.WriteString("func UnwrapResponse[T any](data []byte) (T, error) {\n")
+	b.WriteString("\tvar (\n")
+	b.WriteString("\t\tenvelope Response\n")
+	b.WriteString("\t\tresponse T\n")
+	b.WriteString("\t)\n")
+	b.WriteString("\tif err := json.Unmarshal(data, &envelope); err != nil {\n")
+	b.WriteString("\t\treturn response, err\n")
+	b.WriteString("\t}\n")
+	b.WriteString("\tif err := errors.New(envelope.Error); err != nil {\n")
+	b.WriteString("\t\treturn response, err\n")
+	b.WriteString("\t}\n\n")
+	b.WriteString("\terr := json.Unmarshal(envelope.Response, &response)\n")
+	b.WriteString("\treturn response, err\n")
+	b.WriteString("}\n")
+	return g.writeSource(pkgName+"/support.gen.go", b)
+}
+
+// generateGeneric generates generic Request function, which calls any
+// method by name and unmarshals response into type argument.
+func (g Generator) generateGeneric() error {
+	if !g.generics {
+		return nil
+	}
+
+	b := bytes.NewBuffer(nil)
+	b.WriteString(genPrefix + "\n\npackage " + pkgName + "\n\n")
+	if g.context {
+		b.WriteString("import \"context\"\n\n")
+	}
+	b.WriteString("// Request calls method with params and returns response decoded into T,\n")
+	b.WriteString("// e.g. Request[UsersGetResponse](vk, \"users.get\", params).\n")
+	b.WriteString("func Request[T any](" + g.contextParam() + "vk *VK, method string, params Params) (response T, err error) {\n")
+	b.WriteString("\terr = " + g.requestUnmarshalCall() + "method, params, &response)\n")
+	if g.wrapErrors {
+		b.WriteString("\terr = WrapError(method, err)\n")
+	}
+	b.WriteString("\treturn\n")
+	b.WriteString("}\n")
+	return g.writeSource(pkgName+"/generic.gen.go", b)
+}
+
+// generateValidation generates validate(depth) of objects validated by
+// requests and helper which validates nested values, so errors are
+// prefixed by field path. It generates nothing if requests don't contain
+// constrained objects.
+func (g Generator) generateValidation() error {
+	validated := g.validated()
+	if len(validated) == 0 {
+		return nil
+	}
+
+	objectsSchema, err := g.readSchema(schema.ObjectsSchema)
+	if err != nil {
+		return err
+	}
+	objects, err := g.parser.ParseObjects(objectsSchema)
+	if err != nil {
+		return err
+	}
+
+	var methods strings.Builder
+	imports := []string{"fmt", "reflect"}
+	needErrors, needUTF8 := false, false
+	for _, obj := range objects {
+		if !validated[obj.Name] {
+			continue
+		}
+		gname := g.objectName(obj.Name)
+		props := g.fieldOrder(obj.Expr.Properties)
+		fieldNames := g.fieldNames(gname, props)
+		methods.WriteString("func (obj " + gname + ") validate(depth int) error {\n")
+		for _, prop := range props {
+			field := "obj." + fieldNames[prop.Name]
+			if ref, ok := nestedRef(prop.Expr); ok && validated[ref] {
+				methods.WriteString("\tif err := validateNested(" + strconv.Quote(prop.Name) + ", " + field + ", depth); err != nil {\n")
+				methods.WriteString("\t\treturn err\n")
+				methods.WriteString("\t}\n")
+				continue
+			}
+			checks, lengths := g.propertyChecks(gname, prop, field)
+			if checks != "" {
+				methods.WriteString(checks)
+				needErrors = true
+				needUTF8 = needUTF8 || lengths
+			}
+		}
+		methods.WriteString("\treturn nil\n")
+		methods.WriteString("}\n\n")
+	}
+	if needErrors {
+		imports = append(imports, "errors")
+	}
+	if needUTF8 {
+		imports = append(imports, "unicode/utf8")
+	}
+	sort.Strings(imports)
+
+	b := bytes.NewBuffer(nil)
+	b.WriteString(genPrefix + "\n\npackage " + pkgName + "\n\n")
+	b.WriteString("import (\n")
+	for _, path := range imports {
+		b.WriteString("\t\"" + path + "\"\n")
+	}
+	b.WriteString(")\n\n")
+	b.WriteString(methods.String())
+	b.WriteString(validateNestedFunc)
+	return g.writeSource(pkgName+"/validate.gen.go", b)
+}
+
+const validateNestedFunc = `// maxValidationDepth limits nesting of validated values to guard
+// against cyclic references.
+const maxValidationDepth = 32
+
+type validator interface {
+	validate(depth int) error
+}
+
+// validateNested validates v and elements of slices and arrays,
+// errors are prefixed by path.
+func validateNested(path string, v interface{}, depth int) error {
+	if depth > maxValidationDepth {
+		return fmt.Errorf("%s: validation depth exceeded", path)
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Ptr, reflect.Interface, reflect.Map:
+		if rv.IsNil() {
+			return nil
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			err := validateNested(fmt.Sprintf("%s[%d]", path, i), rv.Index(i).Interface(), depth+1)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if val, ok := v.(validator); ok {
+		if err := val.validate(depth + 1); err != nil {
+			return fmt.Errorf("%s.%w", path, err)
+		}
+	}
+	return nil
+}
+`
+
+// generateFlexBool generates FlexBool type if rules use it.
+func (g Generator) generateFlexBool() error {
+	if !g.rules.usesType(flexBoolName) {
+		return nil
+	}
+
+	b := bytes.NewBuffer(nil)
+	b.WriteString(genPrefix + "\n\npackage " + pkgName + "\n\n")
+	b.WriteString("import (\n")
+	b.WriteString("\t\"encoding/json\"\n")
+	b.WriteString("\t\"fmt\"\n")
+	b.WriteString(")\n\n")
+	b.WriteString(flexBoolType)
+	return g.writeSource(pkgName+"/flexbool.gen.go", b)
+}
+
+const flexBoolName = "FlexBool"
+
+const flexBoolType = `// FlexBool is boolean which VK returns either as JSON boolean or as 1/0
+// integer depending on method.
+type FlexBool bool
+
+// UnmarshalJSON accepts booleans and numbers, non-zero number is true.
+func (b *FlexBool) UnmarshalJSON(data []byte) error {
+	switch string(data) {
+	case "null":
+		return nil
+	case "true", "1":
+		*b = true
+		return nil
+	case "false", "0":
+		*b = false
+		return nil
+	}
+
+	var n float64
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("FlexBool: %s is neither boolean nor number", data)
+	}
+	*b = n != 0
+	return nil
+}
+`
+
+// generateComparable generates compile-time assertions that types intended
+// as map keys are comparable.
+func (g Generator) generateComparable() error {
+	if len(g.comparable) == 0 {
+		return nil
+	}
+
+	b := bytes.NewBuffer(nil)
+	b.WriteString(genPrefix + "\n\npackage " + pkgName + "\n\n")
+	b.WriteString("// Types below are used as map keys and must stay comparable.\n")
+	b.WriteString("var (\n")
+	for _, typ := range g.comparable {
+		b.WriteString("\t_ = map[" + typ + "]struct{}{}\n")
+	}
+	b.WriteString(")\n")
+	return g.writeSource(pkgName+"/comparable.gen.go", b)
+}
+
+// generateProbe generates test which decodes live API responses from probe
+// directory into generated response types with unknown fields disallowed.
+// Fixtures are named after schema responses, e.g. users_get_response.json,
+// so failed subtests show fields missing in the schema.
+func (g Generator) generateProbe() error {
+	if g.probe == "" {
+		return nil
+	}
+
+	dir, err := filepath.Abs(g.probe)
+	if err != nil {
+		return err
+	}
+
+	sch, err := g.readSchema(schema.ResponsesSchema)
+	if err != nil {
+		return err
+	}
+	responses, err := g.parseResponses(sch)
+	if err != nil {
+		return err
+	}
+
+	b := bytes.NewBuffer(nil)
+	b.WriteString(genPrefix + "\n\npackage " + pkgName + "\n\n")
+	b.WriteString("import (\n")
+	b.WriteString("\t\"bytes\"\n")
+	b.WriteString("\t\"encoding/json\"\n")
+	b.WriteString("\t\"io/ioutil\"\n")
+	b.WriteString("\t\"os\"\n")
+	b.WriteString("\t\"path/filepath\"\n")
+	b.WriteString("\t\"testing\"\n")
+	b.WriteString(")\n\n")
+	b.WriteString("const probeDir = " + strconv.Quote(dir) + "\n\n")
+	b.WriteString("// probeResponses maps fixture name to constructor of response type.\n")
+	b.WriteString("var probeResponses = map[string]func() interface{}{\n")
+	for _, resp := range responses {
+		gname := g.responseName(resp.Name)
+		b.WriteString("\t" + strconv.Quote(resp.Name) + ": func() interface{} { return new(" + gname + ") },\n")
+	}
+	b.WriteString("}\n\n")
+	b.WriteString("func TestProbe(t *testing.T) {\n")
+	b.WriteString("\tfor name, newResponse := range probeResponses {\n")
+	b.WriteString("\t\tname, newResponse := name, newResponse\n")
+	b.WriteString("\t\tt.Run(name, func(t *testing.T) {\n")
+	b.WriteString("\t\t\tdata, err := ioutil.ReadFile(filepath.Join(probeDir, name+\".json\"))\n")
+	b.WriteString("\t\t\tif os.IsNotExist(err) {\n")
+	b.WriteString("\t\t\t\tt.Skip(\"no fixture\")\n")
+	b.WriteString("\t\t\t}\n")
+	b.WriteString("\t\t\tif err != nil {\n")
+	b.WriteString("\t\t\t\tt.Fatal(err)\n")
+	b.WriteString("\t\t\t}\n\n")
+	b.WriteString("\t\t\tdec := json.NewDecoder(bytes.NewReader(data))\n")
+	b.WriteString("\t\t\tdec.DisallowUnknownFields()\n")
+	b.WriteString("\t\t\tif err := dec.Decode(newResponse()); err != nil {\n")
+	b.WriteString("\t\t\t\tt.Errorf(\"schema is stale: %v\", err)\n")
+	b.WriteString("\t\t\t}\n")
+	b.WriteString("\t\t})\n")
+	b.WriteString("\t}\n")
+	b.WriteString("}\n")
+	return g.writeSource(pkgName+"/probe.gen_test.go", b)
+}
+
+// defaultTag returns struct tag with schema default value for
+// github.com/creasty/defaults if default tags are enabled.
+func (g Generator) defaultTag(expr schema.ObjectExpr) string {
+	if !g.defaultTags || expr.Default == nil {
+		return ""
+	}
+	def := *expr.Default
+	if expr.Type == "boolean" {
+		// schema often uses 0 and 1 for boolean defaults
+		switch def {
+		case "0":
+			def = "false"
+		case "1":
+			def = "true"
+		}
+	}
+	return " default:" + strconv.Quote(def)
+}
+
+// extraTags returns additional struct tags of field with JSON name, which
+// follow json tag.
+func (g Generator) extraTags(name string) string {
+	var sb strings.Builder
+	for _, template := range g.fieldTags {
+		i := strings.IndexByte(template, ':')
+		value := fieldTagPlaceholder.ReplaceAllStringFunc(template[i+1:], func(placeholder string) string {
+			return fieldTagNames[placeholder](name)
+		})
+		sb.WriteString(" " + template[:i] + ":" + strconv.Quote(value))
+	}
+	return sb.String()
+}
+
+// snakeCase converts camelCase name to snake_case.
+func snakeCase(name string) string {
+	var sb strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				sb.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// camelCase converts snake_case name to camelCase.
+func camelCase(name string) string {
+	parts := strings.Split(name, "_")
+	for i := 1; i < len(parts); i++ {
+		parts[i] = strings.Title(parts[i])
+	}
+	return strings.Join(parts, "")
+}
+
+// inlineStructs collects anonymous structs hoisted into named types.
+type inlineStructs struct {
+	// dedup makes identical anonymous structs share one type.
+	dedup bool
+	// named makes inline objects of properties types named after parent
+	// type and property.
+	named bool
+	// prefix is prepended to names of deduplicated types.
+	prefix string
+
+	names  map[string]string
+	bodies map[string]string
+	order  []string
+}
+
+func newInlineStructs(dedup, named bool, prefix string) *inlineStructs {
+	return &inlineStructs{
+		dedup:  dedup,
+		named:  named,
+		prefix: prefix,
+		names:  make(map[string]string),
+		bodies: make(map[string]string),
+	}
+}
+
+// name returns name of the type with struct body, identical bodies share
+// one type.
+func (s *inlineStructs) name(body string) string {
+	if name, ok := s.names[body]; ok {
+		return name
+	}
+
+	name := s.prefix + "GeneratedInline" + strconv.Itoa(len(s.names)+1)
+	s.names[body] = name
+	s.add(name, body)
+	return name
+}
+
+// add registers type with name and struct body. Name gets numeric suffix
+// if it is already taken by different body.
+func (s *inlineStructs) add(name, body string) string {
+	unique := name
+	for i := 2; ; i++ {
+		existing, ok := s.bodies[unique]
+		if !ok {
+			break
+		}
+		if existing == body {
+			return unique
+		}
+		unique = name + strconv.Itoa(i)
+	}
+
+	s.bodies[unique] = body
+	s.order = append(s.order, unique)
+	return unique
+}
+
+// generateInlineStructs generates named types of anonymous structs
+// collected by other passes.
+func (g Generator) generateInlineStructs() error {
+	if g.inline == nil {
+		return nil
+	}
+
+	var sb strings.Builder
+	for _, name := range g.inline.order {
+		sb.WriteString("type " + name + " " + g.inline.bodies[name] + "\n")
+	}
+
+	b := bytes.NewBuffer(nil)
+	b.WriteString(genPrefix + "\n\npackage " + pkgName + "\n")
+	writeImports(b, sb.String())
+	b.WriteString("\n" + sb.String())
+	return g.writeSource(pkgName+"/inline.gen.go", b)
+}
+
+// propertyToGolang returns Go type of the property of parent type. Bitmask
+// fields get their flag-set types. Inline objects become types named after
+// parent and property in named inline mode.
+func (g Generator) propertyToGolang(parent string, prop schema.ObjectDefinition) (string, error) {
+	typ, err := g.propertyTypeToGolang(parent, prop)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", prop.Name, err)
+	}
+	return typ, nil
+}
+
+// propertyTypeToGolang returns Go type of the property, errors are not
+// annotated by property name.
+func (g Generator) propertyTypeToGolang(parent string, prop schema.ObjectDefinition) (string, error) {
+	if typ, ok := g.bitmasks.fieldType(parent, g.goify(prop.Name)); ok && prop.Expr.Type == "integer" {
+		return typ, nil
+	}
+
+	if g.inline == nil || !g.inline.named {
+		return g.objectExprToGolang(prop.Expr)
+	}
+
+	expr := prop.Expr
+	prefix := ""
+	for expr.ArrayOf != nil {
+		prefix += "[]"
+		expr = *expr.ArrayOf
+	}
+	name := parent + g.goify(prop.Name)
+	if expr.IsAllOf {
+		typ, err := g.allofExprToGolang(expr)
+		if err != nil {
+			return "", err
+		}
+		return prefix + g.inline.add(name, typ+"\n"), nil
+	}
+	if expr.IsReference || expr.Type != "object" || len(expr.Properties) == 0 {
+		return g.objectExprToGolang(prop.Expr)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("struct{\n")
+	for _, p := range expr.Properties {
+		typ, err := g.propertyToGolang(name, p)
+		if err != nil {
+			return "", err
+		}
+		jtag := "`json:\"" + p.Name + "\"" + g.extraTags(p.Name) + "`"
+		sb.WriteString("\t" + g.goify(p.Name) + " " + typ + " " + jtag + "\n")
+	}
+	sb.WriteString("}\n")
+	return prefix + g.inline.add(name, sb.String()), nil
+}
+
+func (g Generator) goify(name string) string {
+	if g.nogoify {
+		return escapeKeyword(name)
+	}
+
+	// VK schema contains some empty enum names and response keys
+	if strings.TrimSpace(name) == "" {
+		return emptyName
+	}
+
+	runes := []rune(name)
+	runes[0] = unicode.ToUpper(runes[0])
+	for i, r := range runes {
+		if r == '_' || r == ' ' || r == '.' {
+			if i+1 == len(runes) {
+				break
+			}
+			runes[i+1] = unicode.ToUpper(runes[i+1])
+		}
+	}
+
+	goified := g.goifyReplacer.Replace(string(runes))
+	if goified == "" {
+		return emptyName
+	}
+	return escapeKeyword(goified)
+}
+
+// escapeKeyword appends underscore to Go keywords, which may be used as
+// unexported identifiers if names are not gopherized.
+func escapeKeyword(name string) string {
+	if token.IsKeyword(name) {
+		return name + "_"
+	}
+	return name
+}
+
+func (g Generator) ObjectDefinitionToGolang(obj schema.ObjectDefinition) (string, error) {
+	s, err := g.objectDefinitionToGolang(obj)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", obj.Name, err)
+	}
+	gname := g.objectName(obj.Name)
+	return s + g.interfaces.methods(g.receiver("o", gname), gname), nil
+}
+
+// objectName returns Go type name of the object.
+func (g Generator) objectName(name string) string {
+	gname := g.goify(name)
+	if gname == "LeadsComplete" || gname == "LeadsStart" {
+		gname += "Object"
+	}
+	return g.prefix + gname
+}
+
+// typeName returns Go type name of referenced object or response.
+func (g Generator) typeName(name string) string {
+	return g.prefix + g.goify(name)
+}
+
+func (g Generator) objectDefinitionToGolang(obj schema.ObjectDefinition) (string, error) {
+	var sb strings.Builder
+	if desc := g.comment(obj.Expr.Description); desc != nil {
+		sb.WriteString(g.docComment("", *desc))
+	}
+	if obj.Expr.Deprecated {
+		sb.WriteString(deprecatedComment("", obj.Expr.DeprecatedMessage, g.comment(obj.Expr.Description) != nil))
+	}
+
+	gname := g.objectName(obj.Name)
+	if obj.Expr.IsBaseType || obj.Expr.IsReference {
+		gtype, err := g.objectExprToGolang(obj.Expr)
+		if err != nil {
+			return "", err
+		}
+		// alias
+		if isBuiltin(gtype) {
+			sb.WriteString("type " + gname + " = " + gtype + "\n")
+			return sb.String(), nil
+		}
+		sb.WriteString("type " + gname + " " + gtype + "\n")
+		return sb.String(), nil
+	}
+
+	if obj.Expr.IsEnum {
+		enum, err := g.enumToGolang(gname, obj.Expr)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(enum)
+		return sb.String(), nil
+	}
+
+	if obj.Expr.IsAllOf {
+		gtype, err := g.allofExprToGolang(obj.Expr)
+		if err != nil {
+			return "", err
+		}
+		if singleRefAllOf(obj.Expr) {
+			sb.WriteString("type " + g.typeName(obj.Name) + " = " + gtype + "\n")
+			return sb.String(), nil
+		}
+		sb.WriteString("type " + g.typeName(obj.Name) + " " + gtype)
+		if fields := g.rules.emptyArrayFields("objects.gen.go", g.typeName(obj.Name)); len(fields) > 0 {
+			var props []schema.ObjectDefinition
+			fieldNames := make(map[string]string)
+			allofFields, err := g.allofExtractFields(obj.Expr)
+			if err != nil {
+				return "", err
+			}
+			for name := range allofFields {
+				props = append(props, schema.ObjectDefinition{Name: name})
+				fieldNames[name] = g.goify(name)
+			}
+			sort.Slice(props, func(i, j int) bool {
+				return props[i].Name < props[j].Name
+			})
+			method, err := g.emptyArrayMethod(g.typeName(obj.Name), obj.Expr, props, fieldNames, fields)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString("\n" + method)
+		}
+		return sb.String(), nil
+	}
+
+	if obj.Expr.IsOneOf {
+		union, ok, err := g.discriminatedOneOf(gname, obj.Expr)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			sb.WriteString(union)
+			return sb.String(), nil
+		}
+
+		merged, err := g.mergedOneOf(gname, obj.Expr)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(merged)
+		return sb.String(), nil
+	}
+
+	if isMapExpr(obj.Expr) {
+		gtype, err := g.objectExprToGolang(obj.Expr)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString("type " + gname + " " + gtype + "\n")
+		return sb.String(), nil
+	}
+
+	if isEmptyObjectExpr(obj.Expr) {
+		sb.WriteString("type " + gname + " " + g.emptyObjectType() + "\n")
+		return sb.String(), nil
+	}
+
+	requiredFields := make(map[string]struct{})
+	for _, field := range obj.Expr.Required {
+		requiredFields[field] = struct{}{}
+	}
+	allFieldsRequired := len(requiredFields) == 0
+	fieldTypes := make(map[string]string)
+	fieldNames := g.fieldNames(gname, obj.Expr.Properties)
+	sb.WriteString("type " + gname + " struct {\n")
+	for _, prop := range g.fieldOrder(obj.Expr.Properties) {
+		jsonTag := "`json:\"" + prop.Name
+		ptr := false
+		if _, required := requiredFields[prop.Name]; !required && !allFieldsRequired {
+			jsonTag += ",omitempty"
+			ptr = g.optionalMode == OptionalPointer
+		}
+		jsonTag += "\"" + g.defaultTag(prop.Expr) + g.extraTags(prop.Name) + "`"
+		goType, err := g.propertyToGolang(gname, prop)
+		if err != nil {
+			return "", err
+		}
+
+		if prop.Expr.IsReference {
+			ref, err := prop.Expr.Ref()
+			if err != nil {
+				return "", g.schemaErr(prop.Expr, err)
+			}
+			if ptr || g.refersBack(ref.Name, obj.Name) {
+				goType = "*" + goType
+			}
+		}
+
+		above, trailing := g.fieldComment(g.comment(prop.Expr.Description))
+		sb.WriteString(above)
+		if prop.Expr.Deprecated {
+			sb.WriteString(deprecatedComment("\t", prop.Expr.DeprecatedMessage, above != ""))
+		}
+		fieldTypes[prop.Name] = goType
+		sb.WriteString("\t" + fieldNames[prop.Name] + " " + goType + " " + jsonTag + trailing + "\n")
+	}
+
+	if g.preserveUnknown {
+		sb.WriteString("\tunknownFields map[string]json.RawMessage `json:\"-\"`\n")
+	}
+	sb.WriteString("}\n")
+	if g.preserveUnknown {
+		sb.WriteString(preserveUnknownMethods(gname, obj.Expr.Properties))
+	}
+	if g.getters {
+		sb.WriteString(g.getterMethods(gname, g.fieldOrder(obj.Expr.Properties), fieldNames, fieldTypes))
+	}
+	if g.constructors {
+		sb.WriteString(constructorFuncs(gname, g.fieldOrder(obj.Expr.Properties), fieldNames, fieldTypes))
+	}
+	if fields := g.rules.emptyArrayFields("objects.gen.go", gname); len(fields) > 0 {
+		method, err := g.emptyArrayMethod(gname, obj.Expr, obj.Expr.Properties, fieldNames, fields)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(method)
+	}
+	return sb.String(), nil
+}
+
+// discriminatedOneOf generates union of oneOf branches, which unmarshals
+// branch selected by discriminator property. Referenced branches are
+// selected by mapping or definition name, inline branches by single enum
+// value of discriminator property. It reports false if union can't be
+// generated, then branches are merged into struct.
+func (g Generator) discriminatedOneOf(gname string, expr schema.ObjectExpr) (string, bool, error) {
+	disc := expr.Discriminator
+	if !g.oneOfInterfaces || disc == nil || disc.PropertyName == "" {
+		return "", false, nil
+	}
+
+	var types []string
+	mapped := make(map[string]bool)
+	values := make(map[string]string)
+	for value, name := range disc.Mapping {
+		values[value] = g.goify(name)
+		mapped[name] = true
+	}
+	var branches strings.Builder
+	for _, branch := range expr.OneOf {
+		if branch.IsReference {
+			ref, err := branch.Ref()
+			if err != nil {
+				return "", false, g.schemaErr(branch, err)
+			}
+			// aliases of builtin types can't have methods
+			if ref.Expr.IsBaseType {
+				return "", false, nil
+			}
+			typ := g.typeName(ref.Name)
+			if !mapped[ref.Name] {
+				values[ref.Name] = typ
+			}
+			types = append(types, typ)
+			continue
+		}
+
+		var value string
+		for _, prop := range branch.Properties {
+			if prop.Name == disc.PropertyName && len(prop.Expr.Enum) == 1 {
+				value = fmt.Sprint(prop.Expr.Enum[0])
+			}
+		}
+		if value == "" {
+			return "", false, nil
+		}
+		typ := gname + g.goify(value)
+		btype, err := g.objectExprToGolang(branch)
+		if err != nil {
+			return "", false, err
+		}
+		branches.WriteString("type " + typ + " " + btype + "\n\n")
+		values[value] = typ
+		types = append(types, typ)
+	}
+
+	var sortedValues []string
+	for value := range values {
+		sortedValues = append(sortedValues, value)
+	}
+	sort.Strings(sortedValues)
+	// mapping may refer to definitions missing in branches
+	for _, value := range sortedValues {
+		types = append(types, values[value])
+	}
+
+	iface := gname + "Value"
+	marker := "is" + gname + "()"
+
+	var sb strings.Builder
+	sb.WriteString("type " + gname + " struct {\n")
+	sb.WriteString("\tValue " + iface + "\n")
+	sb.WriteString("}\n\n")
+	sb.WriteString("// " + iface + " is implemented by variants of " + gname + ".\n")
+	sb.WriteString("type " + iface + " interface {\n")
+	sb.WriteString("\t" + marker + "\n")
+	sb.WriteString("}\n\n")
+	sb.WriteString(branches.String())
+
+	declared := make(map[string]bool)
+	for _, typ := range types {
+		if declared[typ] {
+			continue
+		}
+		declared[typ] = true
+		sb.WriteString("func (" + typ + ") " + marker + " {}\n\n")
+	}
+
+	sb.WriteString("// UnmarshalJSON unmarshals variant selected by " + disc.PropertyName + ".\n")
+	sb.WriteString("func (o *" + gname + ") UnmarshalJSON(data []byte) error {\n")
+	sb.WriteString("\tvar discriminator struct {\n")
+	sb.WriteString("\t\tValue string `json:\"" + disc.PropertyName + "\"`\n")
+	sb.WriteString("\t}\n")
+	sb.WriteString("\tif err := json.Unmarshal(data, &discriminator); err != nil {\n")
+	sb.WriteString("\t\treturn err\n")
+	sb.WriteString("\t}\n\n")
+	sb.WriteString("\tswitch discriminator.Value {\n")
+	for _, value := range sortedValues {
+		sb.WriteString("\tcase " + strconv.Quote(value) + ":\n")
+		sb.WriteString("\t\tvar v " + values[value] + "\n")
+		sb.WriteString("\t\tif err := json.Unmarshal(data, &v); err != nil {\n")
+		sb.WriteString("\t\t\treturn err\n")
+		sb.WriteString("\t\t}\n")
+		sb.WriteString("\t\to.Value = v\n")
+	}
+	sb.WriteString("\tdefault:\n")
+	sb.WriteString("\t\treturn fmt.Errorf(\"" + gname + ": unknown " + disc.PropertyName + " %q\", discriminator.Value)\n")
+	sb.WriteString("\t}\n")
+	sb.WriteString("\treturn nil\n")
+	sb.WriteString("}\n\n")
+	sb.WriteString("// MarshalJSON marshals variant.\n")
+	sb.WriteString("func (o " + gname + ") MarshalJSON() ([]byte, error) {\n")
+	sb.WriteString("\treturn json.Marshal(o.Value)\n")
+	sb.WriteString("}\n")
+	return sb.String(), true, nil
+}
+
+// mergedOneOf generates struct of oneOf branches: referenced branches are
+// pointer fields and properties of inline branches are optional fields.
+// Payloads don't contain names of referenced branches, so UnmarshalJSON
+// tries branches on the payload and MarshalJSON writes the set branch.
+func (g Generator) mergedOneOf(gname string, expr schema.ObjectExpr) (string, error) {
+	var sb strings.Builder
+	var branches []string
+	// schemas of referenced branches
+	var refBranches []schema.ObjectExpr
+	// fields of inline branches, unmarshaled without referenced branches,
+	// which would promote their (un)marshalers
+	var plain strings.Builder
+	var plainKeys, plainNames []string
+	sb.WriteString("type " + gname + " struct {\n")
+	for _, val := range expr.OneOf {
+		if val.IsReference {
+			ref, err := val.Ref()
+			if err != nil {
+				return "", g.schemaErr(val, err)
+			}
+			typ, err := g.objectExprToGolang(val)
+			if err != nil {
+				return "", err
+			}
+			jtag := "`json:\"" + *&ref.Name + ",omitempty\"`"
+			sb.WriteString("\t*" + typ + " " + jtag + "\n")
+			branches = append(branches, typ)
+			refBranches = append(refBranches, ref.Expr)
+			continue
+		}
+
+		for _, prop := range val.Properties {
+			typ, err := g.objectExprToGolang(prop.Expr)
+			if err != nil {
+				return "", err
+			}
+			jtag := "`json:\"" + prop.Name + ",omitempty\"" + g.extraTags(prop.Name) + "`"
+			field := "\t" + g.goify(prop.Name) + "*" + typ + " " + jtag + "\n"
+			sb.WriteString(field)
+			plain.WriteString("\t" + field)
+			plainKeys = append(plainKeys, strconv.Quote(prop.Name))
+			plainNames = append(plainNames, g.goify(prop.Name))
+		}
+	}
+	sb.WriteString("}\n")
+	if len(branches) == 0 {
+		return sb.String(), nil
+	}
+
+	// branches accepting payload without unknown fields take precedence,
+	// since any object payload decodes into any struct. Decoders ignore
+	// DisallowUnknownFields in UnmarshalJSON of branches, e.g. of structs
+	// preserving unknown fields, so keys of payload are compared with
+	// fields of branches known from schema.
+	sb.WriteString("\n// UnmarshalJSON unmarshals payload into the first branch which accepts it,\n")
+	sb.WriteString("// branches which have all fields of payload are tried first.\n")
+	sb.WriteString("func (o *" + gname + ") UnmarshalJSON(data []byte) error {\n")
+	sb.WriteString("\tdecode := func(v interface{}, strict bool) bool {\n")
+	sb.WriteString("\t\tdec := json.NewDecoder(bytes.NewReader(data))\n")
+	sb.WriteString("\t\tif strict {\n")
+	sb.WriteString("\t\t\tdec.DisallowUnknownFields()\n")
+	sb.WriteString("\t\t}\n")
+	sb.WriteString("\t\treturn dec.Decode(v) == nil\n")
+	sb.WriteString("\t}\n\n")
+	sb.WriteString("\t// non-object payloads have no keys\n")
+	sb.WriteString("\tvar payload map[string]json.RawMessage\n")
+	sb.WriteString("\t_ = json.Unmarshal(data, &payload)\n")
+	sb.WriteString("\tknown := func(fields ...string) bool {\n")
+	sb.WriteString("\t\tfor key := range payload {\n")
+	sb.WriteString("\t\t\tfound := false\n")
+	sb.WriteString("\t\t\tfor _, field := range fields {\n")
+	sb.WriteString("\t\t\t\tfound = found || key == field\n")
+	sb.WriteString("\t\t\t}\n")
+	sb.WriteString("\t\t\tif !found {\n")
+	sb.WriteString("\t\t\t\treturn false\n")
+	sb.WriteString("\t\t\t}\n")
+	sb.WriteString("\t\t}\n")
+	sb.WriteString("\t\treturn true\n")
+	sb.WriteString("\t}\n\n")
+	if plain.Len() > 0 {
+		sb.WriteString("\ttype plain struct {\n")
+		sb.WriteString(plain.String())
+		sb.WriteString("\t}\n")
+	}
+	sb.WriteString("\tfor _, strict := range []bool{true, false} {\n")
+	for i, typ := range branches {
+		cond := "decode(v, strict)"
+		if keys, ok := g.jsonKeys(refBranches[i]); ok {
+			cond = "(!strict || known(" + keys + ")) && " + cond
+		}
+		sb.WriteString("\t\tif v := new(" + typ + "); " + cond + " {\n")
+		sb.WriteString("\t\t\t*o = " + gname + "{" + typ + ": v}\n")
+		sb.WriteString("\t\t\treturn nil\n")
+		sb.WriteString("\t\t}\n")
+	}
+	// fields copied between merged struct and plain struct from value
+	copyFields := func(from string) string {
+		fields := make([]string, len(plainNames))
+		for i, name := range plainNames {
+			fields[i] = name + ": " + from + "." + name
+		}
+		return strings.Join(fields, ", ")
+	}
+	if plain.Len() > 0 {
+		sb.WriteString("\t\tif v := new(plain); (!strict || known(" + strings.Join(plainKeys, ", ") + ")) && decode(v, strict) {\n")
+		sb.WriteString("\t\t\t*o = " + gname + "{" + copyFields("v") + "}\n")
+		sb.WriteString("\t\t\treturn nil\n")
+		sb.WriteString("\t\t}\n")
+	}
+	sb.WriteString("\t}\n")
+	sb.WriteString("\treturn fmt.Errorf(\"" + gname + ": payload matches no oneOf branch: %s\", data)\n")
+	sb.WriteString("}\n\n")
+
+	sb.WriteString("// MarshalJSON marshals the first set branch.\n")
+	sb.WriteString("func (o " + gname + ") MarshalJSON() ([]byte, error) {\n")
+	sb.WriteString("\tswitch {\n")
+	for _, typ := range branches {
+		sb.WriteString("\tcase o." + typ + " != nil:\n")
+		sb.WriteString("\t\treturn json.Marshal(o." + typ + ")\n")
+	}
+	sb.WriteString("\t}\n")
+	if plain.Len() > 0 {
+		sb.WriteString("\ttype plain struct {\n")
+		sb.WriteString(plain.String())
+		sb.WriteString("\t}\n")
+		sb.WriteString("\treturn json.Marshal(plain{" + copyFields("o") + "})\n")
+	} else {
+		sb.WriteString("\treturn []byte(\"null\"), nil\n")
+	}
+	sb.WriteString("}\n")
+	return sb.String(), nil
+}
+
+// jsonKeys returns quoted names of JSON fields of struct generated for
+// expr. It reports false if fields are unknown, e.g. of maps and unions.
+func (g Generator) jsonKeys(expr schema.ObjectExpr) (string, bool) {
+	var names []string
+	switch {
+	case expr.IsReference:
+		ref, err := expr.Ref()
+		if err != nil {
+			return "", false
+		}
+		return g.jsonKeys(ref.Expr)
+	case expr.IsAllOf:
+		if singleRefAllOf(expr) {
+			return g.jsonKeys(expr.AllOf[0])
+		}
+		fields, err := g.allofExtractFields(expr)
+		if err != nil {
+			return "", false
+		}
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	case expr.IsOneOf || expr.IsEnum || expr.IsBaseType || len(expr.Properties) == 0:
+		return "", false
+	default:
+		for _, prop := range expr.Properties {
+			names = append(names, prop.Name)
+		}
+	}
+
+	keys := make([]string, len(names))
+	for i, name := range names {
+		keys[i] = strconv.Quote(name)
+	}
+	return strings.Join(keys, ", "), true
+}
+
+// comment returns description of generated entry, nil if comments are
+// disabled.
+func (g Generator) comment(description *string) *string {
+	if g.noComments {
+		return nil
+	}
+	return description
+}
+
+// docComment returns comment lines of text wrapped at comment width on
+// word boundaries.
+func (g Generator) docComment(indent, text string) string {
+	if g.commentWidth <= 0 {
+		return indent + "// " + text + "\n"
+	}
+
+	var sb strings.Builder
+	line := ""
+	for _, word := range strings.Fields(text) {
+		if line != "" && utf8.RuneCountInString("// "+line+" "+word) > g.commentWidth {
+			sb.WriteString(indent + "// " + line + "\n")
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	sb.WriteString(indent + "// " + line + "\n")
+	return sb.String()
+}
+
+// fieldComment returns description of struct field as trailing comment, or
+// as comment above field if it is wider than comment width.
+func (g Generator) fieldComment(description *string) (above, trailing string) {
+	if description == nil {
+		return "", ""
+	}
+	if g.commentWidth > 0 && utf8.RuneCountInString("// "+*description) > g.commentWidth {
+		return g.docComment("\t", *description), ""
+	}
+	return "", " // " + *description
+}
+
+// deprecatedComment returns "Deprecated:" paragraph of doc comment, which
+// is separated from preceding doc text if any.
+func deprecatedComment(indent, message string, afterDoc bool) string {
+	if message == "" {
+		message = "Deprecated in the VK API schema."
+	}
+
+	var sb strings.Builder
+	if afterDoc {
+		sb.WriteString(indent + "//\n")
+	}
+	sb.WriteString(indent + "// Deprecated: " + message + "\n")
+	return sb.String()
+}
+
+func (g Generator) objectExprToGolang(expr schema.ObjectExpr) (string, error) {
+	if expr.IsReference {
+		ref, err := expr.Ref()
+		if err != nil {
+			return "", g.schemaErr(expr, err)
+		}
+		return g.typeName(*&ref.Name), nil
+	}
+
+	if expr.IsAllOf {
+		return g.allofExprToGolang(expr)
+	}
+
+	if isMapExpr(expr) {
+		typ, err := g.mapValueType(expr)
+		if err != nil {
+			return "", err
+		}
+		return "map[string]" + typ, nil
+	}
+
+	switch expr.Type {
+	case "integer", "number":
+		if g.jsonNumber {
+			return "json.Number", nil
+		}
+		if expr.Type == "integer" {
+			// format of integer overrides selected type
+			if expr.Format == "int32" || expr.Format == "int64" {
+				return expr.Format, nil
+			}
+			return g.intType, nil
+		}
+		return "float64", nil
+	case "string":
+		// time.Time unmarshals RFC 3339 strings itself
+		if g.timeFormat && expr.Format == "date-time" {
+			return "time.Time", nil
+		}
+		return "string", nil
+	case "boolean":
+		return "bool", nil
+	case "array":
+		typ, err := g.objectExprToGolang(*expr.ArrayOf)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + typ, nil
+	case "object":
+		if len(expr.Properties) > 0 {
+			var sb strings.Builder
+			sb.WriteString("struct{\n")
+			for _, prop := range expr.Properties {
+				typ, err := g.objectExprToGolang(prop.Expr)
+				if err != nil {
+					return "", err
+				}
+				jtag := "`json:\"" + prop.Name + "\"" + g.extraTags(prop.Name) + "`"
+				sb.WriteString("\t" + g.goify(prop.Name) + " " + typ + " " + jtag + "\n")
+			}
+			sb.WriteString("}\n")
+			if g.inline != nil && g.inline.dedup {
+				return g.inline.name(sb.String()), nil
+			}
+			return sb.String(), nil
+		}
+		fallthrough
+	default:
+		if typ, ok := unknownTypes[g.unknownType]; ok {
+			return typ, nil
+		}
+		return unknownTypes[UnknownAny], nil
+	}
+}
+
+// fieldNames maps properties to Go names of struct fields. Properties
+// colliding after goify get numeric suffix in schema order.
+func (g Generator) fieldNames(gname string, props []schema.ObjectDefinition) map[string]string {
+	names := make(map[string]string, len(props))
+	used := make(map[string]string)
+	for _, prop := range props {
+		name := g.goify(prop.Name)
+		if first, ok := used[name]; ok {
+			base := name
+			for i := 2; ; i++ {
+				name = base + strconv.Itoa(i)
+				if _, ok := used[name]; !ok {
+					break
+				}
+			}
+			if g.debug {
+				log.Printf("%s: property %q collides with %q, field renamed to %s", gname, prop.Name, first, name)
+			}
+		}
+		used[name] = prop.Name
+		names[prop.Name] = name
+	}
+	return names
+}
+
+// fieldOrder returns properties in order of struct fields: schema order
+// or alphabetical order of Go field names if fields are sorted.
+func (g Generator) fieldOrder(props []schema.ObjectDefinition) []schema.ObjectDefinition {
+	if !g.sortFields {
+		return props
+	}
+
+	sorted := make([]schema.ObjectDefinition, len(props))
+	copy(sorted, props)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return g.goify(sorted[i].Name) < g.goify(sorted[j].Name)
+	})
+	return sorted
+}
+
+// paramExprToGolang returns type of method parameter. Parameters keep
+// date-time strings and numbers as is, since VK expects them in its own
+// formats.
+func (g Generator) paramExprToGolang(expr schema.ObjectExpr) (string, error) {
+	g.timeFormat = false
+	g.jsonNumber = false
+	g.unknownType = UnknownAny
+	return g.objectExprToGolang(expr)
+}
+
+// isEmptyObjectExpr reports whether expr has no properties, type and reference.
+func isEmptyObjectExpr(expr schema.ObjectExpr) bool {
+	return len(expr.Properties) == 0 && expr.Type == "" && !expr.IsReference && expr.AdditionalProperties == nil && len(expr.PatternProperties) == 0
+}
+
+// isMapExpr reports whether object is string-keyed map.
+func isMapExpr(expr schema.ObjectExpr) bool {
+	return len(expr.Properties) == 0 && (expr.AdditionalProperties != nil || len(expr.PatternProperties) > 0)
+}
+
+// mapValueType returns Go type of values of map object. Values of
+// properties matching different patterns are json.RawMessage unless
+// patterns have the same type.
+func (g Generator) mapValueType(expr schema.ObjectExpr) (string, error) {
+	if expr.AdditionalProperties != nil {
+		return g.objectExprToGolang(*expr.AdditionalProperties)
+	}
+
+	typ, err := g.objectExprToGolang(expr.PatternProperties[0].Expr)
+	if err != nil {
+		return "", err
+	}
+	for _, pattern := range expr.PatternProperties[1:] {
+		ptype, err := g.objectExprToGolang(pattern.Expr)
+		if err != nil {
+			return "", err
+		}
+		if ptype != typ {
+			return "json.RawMessage", nil
+		}
+	}
+	return typ, nil
+}
+
+func (g Generator) emptyObjectType() string {
+	if typ, ok := emptyObjectPolicies[g.emptyObjects]; ok {
+		return typ
+	}
+	return emptyObjectPolicies[EmptyObjectStruct]
+}
+
+var responseRules = map[string]string{
+	"messages_delete_response": "map[string]int64",
+}
+
+func (g Generator) ResponseDefinitionToGolang(resp schema.ResponseDefinition) (string, error) {
+	s, err := g.responseDefinitionToGolang(resp)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", resp.Name, err)
+	}
+	return s, nil
+}
+
+// responseName returns Go type name of the response.
+func (g Generator) responseName(name string) string {
+	gname := g.goify(name)
+	if !strings.HasSuffix(gname, "Response") {
+		gname = gname + "Response"
+	}
+	return g.prefix + gname
+}
+
+func (g Generator) responseDefinitionToGolang(resp schema.ResponseDefinition) (string, error) {
+	var sb strings.Builder
+	if desc := g.comment(resp.Expr.Description); desc != nil {
+		sb.WriteString(g.docComment("", *desc))
+	}
+	gname := g.responseName(resp.Name)
+	if forcedType, ok := responseRules[resp.Name]; ok {
+		sb.WriteString("type " + gname + " " + forcedType + "\n")
+		return sb.String(), nil
+	}
+
+	if resp.Expr.IsBaseType || resp.Expr.IsReference {
+		gtype, err := g.objectExprToGolang(resp.Expr.ObjectExpr)
+		if err != nil {
+			return "", err
+		}
+		// alias
+		if isBuiltin(gtype) {
+			sb.WriteString("type " + gname + " = " + gtype + "\n")
+			return sb.String(), nil
+		}
+		sb.WriteString("type " + gname + " " + gtype + "\n")
+		return sb.String(), nil
+	}
+
+	if resp.Expr.IsEnum {
+		if desc := g.comment(resp.Expr.Description); desc != nil {
+			sb.WriteString(g.docComment("", *desc))
+		}
+		enum, err := g.enumToGolang(gname, resp.Expr.ObjectExpr)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(enum)
+		return sb.String(), nil
+	}
+
+	if resp.Expr.IsAllOf {
+		gtype, err := g.allofExprToGolang(resp.Expr.ObjectExpr)
+		if err != nil {
+			return "", err
+		}
+		if singleRefAllOf(resp.Expr.ObjectExpr) {
+			return "type " + gname + " = " + gtype + "\n", nil
+		}
+		s := "// allof" + resp.Name
+		s = "type " + gname + " " + gtype
+		return s, nil
+	}
+
+	if resp.Expr.IsOneOf {
+		union, ok, err := g.discriminatedOneOf(gname, resp.Expr.ObjectExpr)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			sb.WriteString(union)
+			return sb.String(), nil
+		}
+
+		merged, err := g.mergedOneOf(gname, resp.Expr.ObjectExpr)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(merged)
+		return sb.String(), nil
+	}
+
+	if isMapExpr(resp.Expr.ObjectExpr) {
+		gtype, err := g.objectExprToGolang(resp.Expr.ObjectExpr)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString("type " + gname + " " + gtype + "\n")
+		return sb.String(), nil
+	}
+
+	if isEmptyObjectExpr(resp.Expr.ObjectExpr) {
+		sb.WriteString("type " + gname + " " + g.emptyObjectType() + "\n")
+		return sb.String(), nil
+	}
+
+	requiredFields := make(map[string]struct{})
+	for _, field := range resp.Expr.Required {
+		requiredFields[field] = struct{}{}
+	}
+	allFieldsRequired := len(requiredFields) == 0
+	fieldTypes := make(map[string]string)
+	fieldNames := g.fieldNames(gname, resp.Expr.Properties)
+	sb.WriteString("type " + gname + " struct {\n")
+	for _, prop := range g.fieldOrder(resp.Expr.Properties) {
+		jsonTag := "`json:\"" + prop.Name
+		ptr := false
+		if _, required := requiredFields[prop.Name]; !required && !allFieldsRequired {
+			jsonTag += ",omitempty"
+			ptr = g.optionalMode == OptionalPointer
+		}
+		jsonTag += "\"" + g.extraTags(prop.Name) + "`"
+		goType, err := g.propertyToGolang(gname, prop)
+		if err != nil {
+			return "", err
+		}
+
+		if prop.Expr.IsReference {
+			ref, err := prop.Expr.Ref()
+			if err != nil {
+				return "", g.schemaErr(prop.Expr, err)
+			}
+			if resp.Name == *&ref.Name || ptr {
+				goType = "*" + goType
+			}
+		}
+
+		above, trailing := g.fieldComment(g.comment(prop.Expr.Description))
+		sb.WriteString(above)
+		fieldTypes[prop.Name] = goType
+		sb.WriteString("\t" + fieldNames[prop.Name] + " " + goType + " " + jsonTag + trailing + "\n")
 	}
 
-	gname := g.goify(obj.Name)
-	if gname == "LeadsComplete" || gname == "LeadsStart" {
-		gname += "Object"
+	if g.preserveUnknown {
+		sb.WriteString("\tunknownFields map[string]json.RawMessage `json:\"-\"`\n")
 	}
-	if obj.Expr.IsBaseType || obj.Expr.IsReference {
-		gtype := g.objectExprToGolang(obj.Expr)
-		// alias
-		if isBuiltin(gtype) {
-			sb.WriteString("type " + gname + " = " + gtype + "\n")
-			return sb.String()
+	sb.WriteString("}\n")
+	if g.preserveUnknown {
+		sb.WriteString(preserveUnknownMethods(gname, resp.Expr.Properties))
+	}
+	if g.getters {
+		sb.WriteString(g.getterMethods(gname, g.fieldOrder(resp.Expr.Properties), fieldNames, fieldTypes))
+	}
+	if g.constructors {
+		sb.WriteString(constructorFuncs(gname, g.fieldOrder(resp.Expr.Properties), fieldNames, fieldTypes))
+	}
+	if fields := g.rules.emptyArrayFields("responses.gen.go", gname); len(fields) > 0 {
+		method, err := g.emptyArrayMethod(gname, resp.Expr.ObjectExpr, resp.Expr.Properties, fieldNames, fields)
+		if err != nil {
+			return "", err
 		}
-		sb.WriteString("type " + gname + " " + gtype + "\n")
-		return sb.String()
+		sb.WriteString(method)
 	}
+	if g.summaries {
+		sb.WriteString(g.summaryMethod(gname, resp.Expr.Properties, fieldNames, fieldTypes))
+	}
+	if g.extendedMerge && strings.Contains(strings.ToLower(resp.Name), "extended") {
+		method, err := g.extendedAppendMethod(gname, resp.Expr.Properties)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(method)
+	}
+	return sb.String(), nil
+}
 
-	if obj.Expr.IsEnum {
-		sb.WriteString("type " + gname + " " + g.objectExprToGolang(obj.Expr) + "\n")
-		if len(obj.Expr.Enum) == 0 {
-			return sb.String()
-		}
-
-		sb.WriteString("\nconst (\n")
-		for idx, item := range obj.Expr.Enum {
-			val := "undefined"
-			isString := false
-			switch obj.Expr.Type {
-			case "number":
-				val = strconv.FormatFloat(item.(float64), 'g', 10, 64)
-			case "integer":
-				val = strconv.FormatInt(item.(int64), 10)
-			case "string":
-				val = item.(string)
-				isString = true
-			default:
-				panic("unsupported enum type")
-			}
-
-			fieldNamePostfix := val
-			if len(obj.Expr.EnumNames) > 0 {
-				fieldNamePostfix = obj.Expr.EnumNames[idx]
-			}
+// maxSummaryFields limits number of fields in response summary.
+const maxSummaryFields = 3
 
-			if isString {
-				val = `"` + val + `"`
-			}
+// summaryStringFunc truncates long strings in response summaries.
+const summaryStringFunc = `
+// summaryString returns quoted s truncated to 64 runes.
+func summaryString(s string) string {
+	const max = 64
+	if r := []rune(s); len(r) > max {
+		s = string(r[:max]) + "..."
+	}
+	return strconv.Quote(s)
+}
+`
 
-			fieldName := gname + g.goify(fieldNamePostfix)
-			sb.WriteString("\t" + fieldName + " " + gname + " = " + val + "\n")
+// sensitiveField reports whether field may hold credentials, which must
+// not get into logs.
+func sensitiveField(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range []string{"token", "secret", "password", "hash", "key"} {
+		if strings.Contains(name, word) {
+			return true
 		}
-		sb.WriteString(")\n")
-		return sb.String()
 	}
+	return false
+}
 
-	if obj.Expr.IsAllOf {
-		s := "// allof " + obj.Name
-		s = "type " + g.goify(obj.Name) + " " + g.allofExprToGolang(obj.Expr)
-		return s
+// summaryMethod generates Summary method of response. Summary contains
+// count and number of items if present, then other scalar fields and
+// lengths of slices in schema order except credentials.
+func (g Generator) summaryMethod(gname string, props []schema.ObjectDefinition, fieldNames, fieldTypes map[string]string) string {
+	summarized := func(typ string) bool {
+		switch typ {
+		case "int", "int32", "int64", "float64", "json.Number", "string", "bool":
+			return true
+		}
+		return strings.HasPrefix(typ, "[]")
 	}
 
-	if obj.Expr.IsOneOf {
-		var values []schema.ObjectExpr = obj.Expr.OneOf
-
-		sb.WriteString("type " + gname + " struct {\n")
-		for _, val := range values {
-			if val.IsReference {
-				ref, err := val.Ref()
-				if err != nil {
-					panic(err)
-				}
-				jtag := "`json:\"" + *&ref.Name + ",omitempty\"`"
-				sb.WriteString("\t*" + g.objectExprToGolang(val) + " " + jtag + "\n")
-				continue
-			}
+	var names []string
+	if summarized(fieldTypes["count"]) {
+		names = append(names, "count")
+	}
+	if strings.HasPrefix(fieldTypes["items"], "[]") {
+		names = append(names, "items")
+	}
+	for _, prop := range props {
+		if len(names) == maxSummaryFields {
+			break
+		}
+		if prop.Name == "count" || prop.Name == "items" || !summarized(fieldTypes[prop.Name]) || sensitiveField(prop.Name) {
+			continue
+		}
+		names = append(names, prop.Name)
+	}
 
-			for _, prop := range val.Properties {
-				jtag := "`json:\"" + prop.Name + ",omitempty\"`"
-				sb.WriteString("\t" + g.goify(prop.Name) + "*" + g.objectExprToGolang(prop.Expr) + " " + jtag + "\n")
-			}
+	var format, args []string
+	for _, name := range names {
+		field := "r." + fieldNames[name]
+		switch typ := fieldTypes[name]; {
+		case strings.HasPrefix(typ, "[]"):
+			format = append(format, name+"=%d")
+			args = append(args, "len("+field+")")
+		case typ == "string":
+			format = append(format, name+"=%s")
+			args = append(args, "summaryString("+field+")")
+		default:
+			format = append(format, name+"=%v")
+			args = append(args, field)
 		}
-		sb.WriteString("}\n")
-		return sb.String()
 	}
 
-	sb.WriteString("type " + gname + " struct {\n")
-	for _, prop := range obj.Expr.Properties {
-		jsonTag := "`json:\"" + prop.Name
-		jsonTag += "\"`"
-		goType := g.objectExprToGolang(prop.Expr)
+	var sb strings.Builder
+	sb.WriteString("\n// Summary returns short description of response for logging.\n")
+	sb.WriteString("func (" + g.receiver("r", gname) + ") Summary() string {\n")
+	if len(names) == 0 {
+		sb.WriteString("\treturn \"" + gname + "\"\n")
+	} else {
+		sb.WriteString("\treturn fmt.Sprintf(\"" + strings.Join(format, " ") + "\", " + strings.Join(args, ", ") + ")\n")
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
 
-		if prop.Expr.IsReference {
-			ref, err := prop.Expr.Ref()
+// extendedAppendMethod generates Append method which concatenates items and
+// auxiliary profiles and groups of extended response pages. Profiles and
+// groups are deduplicated by id.
+func (g Generator) extendedAppendMethod(gname string, props []schema.ObjectDefinition) (string, error) {
+	var items, aux []schema.ObjectDefinition
+	for _, prop := range props {
+		if prop.Expr.ArrayOf == nil {
+			continue
+		}
+		switch prop.Name {
+		case "items":
+			items = append(items, prop)
+		case "profiles", "groups":
+			_, ok, err := g.propertyType(*prop.Expr.ArrayOf, "id")
 			if err != nil {
-				panic(err)
+				return "", err
 			}
-			if obj.Name == *&ref.Name {
-				goType = "*" + goType
+			if ok {
+				aux = append(aux, prop)
 			}
 		}
+	}
+	if len(aux) == 0 {
+		return "", nil
+	}
 
-		if prop.Expr.Description != nil {
-			jsonTag += " // " + *prop.Expr.Description
+	var sb strings.Builder
+	sb.WriteString("\n// Append concatenates items, profiles and groups of response pages.\n")
+	sb.WriteString("// Profiles and groups are deduplicated by id.\n")
+	if g.receiverKind == ReceiverValue {
+		sb.WriteString("func (a " + gname + ") Append(b " + gname + ") " + gname + " {\n")
+	} else {
+		// receiver is not modified
+		sb.WriteString("func (p *" + gname + ") Append(b " + gname + ") " + gname + " {\n")
+		sb.WriteString("\ta := *p\n")
+	}
+	// slices of a share backing arrays with slices of caller, so they are
+	// copied before appending
+	for _, prop := range append(items, aux...) {
+		field := g.goify(prop.Name)
+		typ, err := g.objectExprToGolang(prop.Expr)
+		if err != nil {
+			return "", err
 		}
-
-		sb.WriteString("\t" + g.goify(prop.Name) + " " + goType + " " + jsonTag + "\n")
+		sb.WriteString("\t" + prop.Name + " := make(" + typ + ", len(a." + field + "), len(a." + field + ")+len(b." + field + "))\n")
+		sb.WriteString("\tcopy(" + prop.Name + ", a." + field + ")\n")
+		sb.WriteString("\ta." + field + " = " + prop.Name + "\n")
 	}
-
+	for _, prop := range items {
+		field := g.goify(prop.Name)
+		sb.WriteString("\ta." + field + " = append(a." + field + ", b." + field + "...)\n")
+	}
+	for _, prop := range aux {
+		field := g.goify(prop.Name)
+		idType, _, err := g.propertyType(*prop.Expr.ArrayOf, "id")
+		if err != nil {
+			return "", err
+		}
+		seen := "seen" + field
+		sb.WriteString("\n\t" + seen + " := make(map[" + idType + "]struct{}, len(a." + field + "))\n")
+		sb.WriteString("\tfor _, v := range a." + field + " {\n")
+		sb.WriteString("\t\t" + seen + "[v.ID] = struct{}{}\n")
+		sb.WriteString("\t}\n")
+		sb.WriteString("\tfor _, v := range b." + field + " {\n")
+		sb.WriteString("\t\tif _, ok := " + seen + "[v.ID]; !ok {\n")
+		sb.WriteString("\t\t\t" + seen + "[v.ID] = struct{}{}\n")
+		sb.WriteString("\t\t\ta." + field + " = append(a." + field + ", v)\n")
+		sb.WriteString("\t\t}\n")
+		sb.WriteString("\t}\n")
+	}
+	sb.WriteString("\treturn a\n")
 	sb.WriteString("}\n")
-	return sb.String()
+	return sb.String(), nil
 }
 
-func (g Generator) objectExprToGolang(expr schema.ObjectExpr) string {
+// propertyType returns Go type of property name of the object expression,
+// resolving references and allOf.
+func (g Generator) propertyType(expr schema.ObjectExpr, name string) (string, bool, error) {
 	if expr.IsReference {
 		ref, err := expr.Ref()
 		if err != nil {
-			panic(err)
+			return "", false, g.schemaErr(expr, err)
 		}
-		return g.goify(*&ref.Name)
+		return g.propertyType(ref.Expr, name)
 	}
 
 	if expr.IsAllOf {
-		return g.allofExprToGolang(expr)
+		allofFields, err := g.allofExtractFields(expr)
+		if err != nil {
+			return "", false, err
+		}
+		fields, ok := allofFields[name]
+		if !ok || len(fields) == 0 {
+			return "", false, nil
+		}
+		typ, err := g.objectExprToGolang(fields[0])
+		return typ, err == nil, err
 	}
 
-	switch expr.Type {
-	case "integer":
-		return "int64"
-	case "number":
-		return "float64"
-	case "string":
-		return "string"
-	case "boolean":
-		return "bool"
-	case "array":
-		return "[]" + g.objectExprToGolang(*expr.ArrayOf)
-	case "object":
-		if len(expr.Properties) > 0 {
-			var sb strings.Builder
-			sb.WriteString("struct{\n")
-			for _, prop := range expr.Properties {
-				jtag := "`json:\"" + prop.Name + "\"`"
-				sb.WriteString("\t" + g.goify(prop.Name) + " " + g.objectExprToGolang(prop.Expr) + " " + jtag + "\n")
-			}
-			sb.WriteString("}\n")
-			return sb.String()
+	for _, prop := range expr.Properties {
+		if prop.Name == name {
+			typ, err := g.objectExprToGolang(prop.Expr)
+			return typ, err == nil, err
 		}
-		fallthrough
-	default:
-		return "interface{}"
 	}
+	return "", false, nil
 }
 
-var responseRules = map[string]string{
-	"messages_delete_response": "map[string]int64",
-}
+// enumToGolang generates enum type with constants and methods.
+func (g Generator) enumToGolang(gname string, expr schema.ObjectExpr) (string, error) {
+	// enum values are constants, so numeric enums keep numeric types
+	g.jsonNumber = false
 
-func (g Generator) ResponseDefinitionToGolang(resp schema.ResponseDefinition) string {
 	var sb strings.Builder
-	if resp.Expr.Description != nil {
-		sb.WriteString("// " + *resp.Expr.Description + "\n")
+	if g.enumIntBacked && expr.Type == "string" {
+		sb.WriteString("type " + gname + " int\n")
+	} else {
+		typ, err := g.objectExprToGolang(expr)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString("type " + gname + " " + typ + "\n")
 	}
-	gname := g.goify(resp.Name)
-	if !strings.HasSuffix(gname, "Response") {
-		gname = gname + "Response"
+	if len(expr.Enum) == 0 {
+		return sb.String(), nil
 	}
-	if forcedType, ok := responseRules[resp.Name]; ok {
-		sb.WriteString("type " + gname + " " + forcedType + "\n")
-		return sb.String()
+
+	if len(expr.EnumNames) > 0 && len(expr.EnumNames) != len(expr.Enum) && g.debug {
+		log.Printf("%s: %d enum names for %d values, values without names are named after values", gname, len(expr.EnumNames), len(expr.Enum))
 	}
 
-	if resp.Expr.IsBaseType || resp.Expr.IsReference {
-		gtype := g.objectExprToGolang(resp.Expr.ObjectExpr)
-		// alias
-		if isBuiltin(gtype) {
-			sb.WriteString("type " + gname + " = " + gtype + "\n")
-			return sb.String()
+	var fieldNames, labels, values []string
+	sb.WriteString("\nconst (\n")
+	for idx, item := range expr.Enum {
+		val := "undefined"
+		isString := false
+		switch expr.Type {
+		case "number":
+			val = strconv.FormatFloat(item.(float64), 'g', 10, 64)
+		case "integer":
+			val = strconv.FormatInt(item.(int64), 10)
+		case "string":
+			val = item.(string)
+			isString = true
+		default:
+			return "", g.schemaErr(expr, fmt.Errorf("unsupported enum type %q", expr.Type))
 		}
-		sb.WriteString("type " + gname + " " + gtype + "\n")
-		return sb.String()
+
+		fieldNamePostfix := val
+		enumName := ""
+		if idx < len(expr.EnumNames) {
+			enumName = expr.EnumNames[idx]
+			fieldNamePostfix = enumName
+		}
+
+		if isString {
+			values = append(values, val)
+			val = `"` + val + `"`
+		}
+
+		fieldName := gname + g.goify(enumPostfix(fieldNamePostfix))
+		label := ""
+		if !g.noComments && strings.TrimSpace(enumName) != "" {
+			label = " // " + strings.Join(strings.Fields(enumName), " ")
+		}
+		switch {
+		case g.enumIntBacked && isString && idx == 0:
+			// zero value is reserved for unknown values
+			sb.WriteString("\t" + fieldName + " " + gname + " = iota + 1" + label + "\n")
+		case g.enumIntBacked && isString:
+			sb.WriteString("\t" + fieldName + label + "\n")
+		default:
+			sb.WriteString("\t" + fieldName + " " + gname + " = " + val + label + "\n")
+		}
+		fieldNames = append(fieldNames, fieldName)
+		labels = append(labels, fieldNamePostfix)
 	}
+	sb.WriteString(")\n")
+	sb.WriteString(enumValuesFunc(gname, fieldNames))
 
-	if resp.Expr.IsEnum {
-		if resp.Expr.Description != nil {
-			sb.WriteString("// " + *resp.Expr.Description + "\n")
-		}
-		sb.WriteString("type " + gname + " " + g.objectExprToGolang(resp.Expr.ObjectExpr) + "\n")
-		if len(resp.Expr.Enum) == 0 {
-			return sb.String()
-		}
-
-		sb.WriteString("\nconst (\n")
-		for idx, item := range resp.Expr.Enum {
-			val := "undefined"
-			isString := false
-			switch resp.Expr.ObjectExpr.Type {
-			case "number":
-				val = strconv.FormatFloat(item.(float64), 'g', 10, 64)
-			case "integer":
-				val = strconv.FormatInt(item.(int64), 10)
-			case "string":
-				val = item.(string)
-				isString = true
-			default:
-				panic("unsupported enum type")
-			}
+	if g.enumIntBacked && expr.Type == "string" {
+		sb.WriteString(enumStringMethod(gname, "integer", fieldNames, labels))
+		sb.WriteString(intBackedEnumMethods(gname, fieldNames, values))
+		return sb.String(), nil
+	}
 
-			fieldNamePostfix := val
-			if len(resp.Expr.EnumNames) > 0 {
-				fieldNamePostfix = resp.Expr.EnumNames[idx]
-			}
+	sb.WriteString(enumStringMethod(gname, expr.Type, fieldNames, labels))
+	if g.strictEnums && expr.Type == "string" {
+		sb.WriteString(strictEnumMethods(gname, fieldNames))
+	}
+	return sb.String(), nil
+}
 
-			if isString {
-				val = `"` + val + `"`
-			}
+// enumPostfix makes numeric enum values usable in constant names, e.g. -1
+// becomes Neg1.
+func enumPostfix(value string) string {
+	if strings.HasPrefix(value, "-") {
+		return "Neg" + value[1:]
+	}
+	return value
+}
 
-			fieldName := gname + g.goify(fieldNamePostfix)
-			sb.WriteString("\t" + fieldName + " " + gname + " = " + val + "\n")
-		}
-		sb.WriteString(")\n")
-		return sb.String()
+// enumValuesFunc generates function which returns all enum constants in
+// declaration order.
+func enumValuesFunc(gname string, fieldNames []string) string {
+	var sb strings.Builder
+	sb.WriteString("\n// " + gname + "Values returns all values of " + gname + ".\n")
+	sb.WriteString("func " + gname + "Values() []" + gname + " {\n")
+	sb.WriteString("\treturn []" + gname + "{\n")
+	for _, fieldName := range fieldNames {
+		sb.WriteString("\t\t" + fieldName + ",\n")
 	}
+	sb.WriteString("\t}\n")
+	sb.WriteString("}\n")
+	return sb.String()
+}
 
-	if resp.Expr.IsAllOf {
-		s := "// allof" + resp.Name
-		s = "type" + g.goify(resp.Name) + " " + g.allofExprToGolang(resp.Expr.ObjectExpr)
-		return s
+// intBackedEnumMethods generates maps between int-backed enum constants and
+// schema values and JSON (un)marshalers which use them.
+func intBackedEnumMethods(gname string, fieldNames, values []string) string {
+	toString := lowerFirst(gname) + "ToString"
+	fromString := lowerFirst(gname) + "FromString"
+
+	var sb strings.Builder
+	sb.WriteString("\nvar " + toString + " = map[" + gname + "]string{\n")
+	for i, fieldName := range fieldNames {
+		sb.WriteString("\t" + fieldName + ": " + strconv.Quote(values[i]) + ",\n")
+	}
+	sb.WriteString("}\n\n")
+	sb.WriteString("var " + fromString + " = map[string]" + gname + "{\n")
+	for i, fieldName := range fieldNames {
+		sb.WriteString("\t" + strconv.Quote(values[i]) + ": " + fieldName + ",\n")
 	}
+	sb.WriteString("}\n\n")
 
-	if resp.Expr.IsOneOf {
-		var values []schema.ObjectExpr = resp.Expr.OneOf
+	sb.WriteString("func (e " + gname + ") MarshalJSON() ([]byte, error) {\n")
+	sb.WriteString("\tif e == 0 {\n")
+	sb.WriteString("\t\treturn []byte(`\"\"`), nil\n")
+	sb.WriteString("\t}\n")
+	sb.WriteString("\ts, ok := " + toString + "[e]\n")
+	sb.WriteString("\tif !ok {\n")
+	sb.WriteString("\t\treturn nil, fmt.Errorf(\"unknown " + gname + " value: %d\", int(e))\n")
+	sb.WriteString("\t}\n")
+	sb.WriteString("\treturn json.Marshal(s)\n")
+	sb.WriteString("}\n\n")
 
-		sb.WriteString("type " + gname + " struct {\n")
-		for _, val := range values {
-			if val.IsReference {
-				ref, err := val.Ref()
-				if err != nil {
-					panic(err)
-				}
-				jtag := "`json:\"" + *&ref.Name + ",omitempty\"`"
-				sb.WriteString("\t*" + g.objectExprToGolang(val) + " " + jtag + "\n")
-				continue
-			}
+	sb.WriteString("func (e *" + gname + ") UnmarshalJSON(data []byte) error {\n")
+	sb.WriteString("\tvar s string\n")
+	sb.WriteString("\tif err := json.Unmarshal(data, &s); err != nil {\n")
+	sb.WriteString("\t\treturn err\n")
+	sb.WriteString("\t}\n")
+	sb.WriteString("\tif s == \"\" {\n")
+	sb.WriteString("\t\t*e = 0\n")
+	sb.WriteString("\t\treturn nil\n")
+	sb.WriteString("\t}\n")
+	sb.WriteString("\tv, ok := " + fromString + "[s]\n")
+	sb.WriteString("\tif !ok {\n")
+	sb.WriteString("\t\treturn fmt.Errorf(\"unknown " + gname + " value: %q\", s)\n")
+	sb.WriteString("\t}\n")
+	sb.WriteString("\t*e = v\n")
+	sb.WriteString("\treturn nil\n")
+	sb.WriteString("}\n")
+	return sb.String()
+}
 
-			for _, prop := range val.Properties {
-				jtag := "`json:\"" + prop.Name + ",omitempty\"`"
-				sb.WriteString("\t" + g.goify(prop.Name) + "*" + g.objectExprToGolang(prop.Expr) + " " + jtag + "\n")
-			}
-		}
-		sb.WriteString("}\n")
-		return sb.String()
+func lowerFirst(s string) string {
+	runes := []rune(s)
+	if len(runes) == 0 {
+		return s
 	}
+	runes[0] = unicode.ToLower(runes[0])
+	return string(runes)
+}
 
-	requiredFields := make(map[string]struct{})
-	for _, field := range resp.Expr.Required {
-		requiredFields[field] = struct{}{}
+// emptyArrayMethod generates UnmarshalJSON which skips fields holding empty
+// JSON arrays, which VK returns instead of missing objects. Fields are
+// decoded separately from raw values shadowing them.
+func (g Generator) emptyArrayMethod(gname string, expr schema.ObjectExpr, props []schema.ObjectDefinition, fieldNames map[string]string, fields map[string]bool) (string, error) {
+	if g.preserveUnknown {
+		return "", g.schemaErr(expr, fmt.Errorf("%s: emptyarray rules can't be combined with preserved unknown fields", gname))
 	}
-	allFieldsRequired := len(requiredFields) == 0
-	sb.WriteString("type " + gname + " struct {\n")
-	for _, prop := range resp.Expr.Properties {
-		jsonTag := "`json:\"" + prop.Name
-		ptr := false
-		if _, required := requiredFields[prop.Name]; !required && !allFieldsRequired {
-			jsonTag += ",omitempty"
-			ptr = true
+
+	var flagged []schema.ObjectDefinition
+	for _, prop := range props {
+		if fields[fieldNames[prop.Name]] {
+			flagged = append(flagged, prop)
 		}
-		jsonTag += "\"`"
-		goType := g.objectExprToGolang(prop.Expr)
+	}
+	if len(flagged) == 0 {
+		return "", nil
+	}
 
-		if prop.Expr.IsReference {
-			ref, err := prop.Expr.Ref()
-			if err != nil {
-				panic(err)
-			}
-			if resp.Name == *&ref.Name || ptr {
-				goType = "*" + goType
-			}
+	var sb strings.Builder
+	sb.WriteString("\n// UnmarshalJSON skips fields holding empty arrays.\n")
+	sb.WriteString("func (o *" + gname + ") UnmarshalJSON(data []byte) error {\n")
+	sb.WriteString("\ttype plain " + gname + "\n")
+	sb.WriteString("\taux := struct {\n")
+	sb.WriteString("\t\t*plain\n")
+	for _, prop := range flagged {
+		sb.WriteString("\t\t" + fieldNames[prop.Name] + " json.RawMessage `json:\"" + prop.Name + "\"`\n")
+	}
+	sb.WriteString("\t}{plain: (*plain)(o)}\n")
+	sb.WriteString("\tif err := json.Unmarshal(data, &aux); err != nil {\n")
+	sb.WriteString("\t\treturn err\n")
+	sb.WriteString("\t}\n")
+	for _, prop := range flagged {
+		field := fieldNames[prop.Name]
+		sb.WriteString("\tif len(aux." + field + ") > 0 && string(aux." + field + ") != \"[]\" {\n")
+		sb.WriteString("\t\tif err := json.Unmarshal(aux." + field + ", &o." + field + "); err != nil {\n")
+		sb.WriteString("\t\t\treturn err\n")
+		sb.WriteString("\t\t}\n")
+		sb.WriteString("\t}\n")
+	}
+	sb.WriteString("\treturn nil\n")
+	sb.WriteString("}\n")
+	return sb.String(), nil
+}
+
+// getterMethods generates getters of pointer fields, which return zero
+// value if field or pointer receiver is nil.
+func (g Generator) getterMethods(gname string, props []schema.ObjectDefinition, fieldNames, fieldTypes map[string]string) string {
+	cond := "o != nil && "
+	if g.receiverKind == ReceiverValue {
+		cond = ""
+	}
+
+	var sb strings.Builder
+	for _, prop := range props {
+		typ := fieldTypes[prop.Name]
+		if !strings.HasPrefix(typ, "*") {
+			continue
 		}
 
-		if prop.Expr.Description != nil {
-			jsonTag += " // " + *prop.Expr.Description
+		field := fieldNames[prop.Name]
+		sb.WriteString("\n// Get" + field + " returns value of " + field + " or zero value if it is unset.\n")
+		sb.WriteString("func (" + g.receiver("o", gname) + ") Get" + field + "() (v " + typ[1:] + ") {\n")
+		sb.WriteString("\tif " + cond + "o." + field + " != nil {\n")
+		sb.WriteString("\t\tv = *o." + field + "\n")
+		sb.WriteString("\t}\n")
+		sb.WriteString("\treturn\n")
+		sb.WriteString("}\n")
+	}
+	return sb.String()
+}
+
+// constructorFuncs generates constructor of struct with functional options
+// setting its fields. Options of pointer fields take values.
+func constructorFuncs(gname string, props []schema.ObjectDefinition, fieldNames, fieldTypes map[string]string) string {
+	option := gname + "Option"
+	var sb strings.Builder
+	sb.WriteString("\n// " + option + " sets field of " + gname + ".\n")
+	sb.WriteString("type " + option + " func(*" + gname + ")\n")
+	sb.WriteString("\n// New" + gname + " returns " + gname + " with options applied.\n")
+	sb.WriteString("func New" + gname + "(opts ..." + option + ") *" + gname + " {\n")
+	sb.WriteString("\to := &" + gname + "{}\n")
+	sb.WriteString("\tfor _, opt := range opts {\n")
+	sb.WriteString("\t\topt(o)\n")
+	sb.WriteString("\t}\n")
+	sb.WriteString("\treturn o\n")
+	sb.WriteString("}\n")
+	for _, prop := range props {
+		field := fieldNames[prop.Name]
+		typ, value := fieldTypes[prop.Name], "v"
+		if strings.HasPrefix(typ, "*") {
+			typ, value = typ[1:], "&v"
 		}
 
-		sb.WriteString("\t" + g.goify(prop.Name) + " " + goType + " " + jsonTag + "\n")
+		fn := gname + "With" + field
+		sb.WriteString("\n// " + fn + " sets " + field + " of " + gname + ".\n")
+		sb.WriteString("func " + fn + "(v " + typ + ") " + option + " {\n")
+		sb.WriteString("\treturn func(o *" + gname + ") {\n")
+		sb.WriteString("\t\to." + field + " = " + value + "\n")
+		sb.WriteString("\t}\n")
+		sb.WriteString("}\n")
+	}
+	return sb.String()
+}
+
+// preserveUnknownMethods generates JSON (un)marshalers which keep fields
+// missing in schema in unknownFields and write them back.
+func preserveUnknownMethods(gname string, props []schema.ObjectDefinition) string {
+	var known []string
+	for _, prop := range props {
+		known = append(known, strconv.Quote(prop.Name))
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\nfunc (o *" + gname + ") UnmarshalJSON(data []byte) error {\n")
+	sb.WriteString("\ttype plain " + gname + "\n")
+	sb.WriteString("\tif err := json.Unmarshal(data, (*plain)(o)); err != nil {\n")
+	sb.WriteString("\t\treturn err\n")
+	sb.WriteString("\t}\n\n")
+	sb.WriteString("\tvar raw map[string]json.RawMessage\n")
+	sb.WriteString("\tif err := json.Unmarshal(data, &raw); err != nil {\n")
+	sb.WriteString("\t\treturn err\n")
+	sb.WriteString("\t}\n")
+	if len(known) > 0 {
+		sb.WriteString("\tfor _, key := range []string{" + strings.Join(known, ", ") + "} {\n")
+		sb.WriteString("\t\tdelete(raw, key)\n")
+		sb.WriteString("\t}\n")
+	}
+	sb.WriteString("\to.unknownFields = nil\n")
+	sb.WriteString("\tif len(raw) > 0 {\n")
+	sb.WriteString("\t\to.unknownFields = raw\n")
+	sb.WriteString("\t}\n")
+	sb.WriteString("\treturn nil\n")
+	sb.WriteString("}\n\n")
+
+	sb.WriteString("func (o " + gname + ") MarshalJSON() ([]byte, error) {\n")
+	sb.WriteString("\ttype plain " + gname + "\n")
+	sb.WriteString("\tdata, err := json.Marshal(plain(o))\n")
+	sb.WriteString("\tif err != nil || len(o.unknownFields) == 0 {\n")
+	sb.WriteString("\t\treturn data, err\n")
+	sb.WriteString("\t}\n\n")
+	sb.WriteString("\tvar raw map[string]json.RawMessage\n")
+	sb.WriteString("\tif err := json.Unmarshal(data, &raw); err != nil {\n")
+	sb.WriteString("\t\treturn nil, err\n")
+	sb.WriteString("\t}\n")
+	sb.WriteString("\tfor key, value := range o.unknownFields {\n")
+	sb.WriteString("\t\tif _, ok := raw[key]; !ok {\n")
+	sb.WriteString("\t\t\traw[key] = value\n")
+	sb.WriteString("\t\t}\n")
+	sb.WriteString("\t}\n")
+	sb.WriteString("\treturn json.Marshal(raw)\n")
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// enumStringMethod generates String method which returns schema value or
+// enum name of the constant.
+func enumStringMethod(gname, typ string, fieldNames, labels []string) string {
+	var sb strings.Builder
+	sb.WriteString("\nfunc (e " + gname + ") String() string {\n")
+	sb.WriteString("\tswitch e {\n")
+	for i, fieldName := range fieldNames {
+		sb.WriteString("\tcase " + fieldName + ":\n")
+		sb.WriteString("\t\treturn " + strconv.Quote(labels[i]) + "\n")
+	}
+	sb.WriteString("\t}\n")
+	switch typ {
+	case "integer":
+		sb.WriteString("\treturn strconv.FormatInt(int64(e), 10)\n")
+	case "number":
+		sb.WriteString("\treturn strconv.FormatFloat(float64(e), 'g', -1, 64)\n")
+	default:
+		sb.WriteString("\treturn string(e)\n")
 	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// strictEnumMethods generates valid method and JSON unmarshaler which
+// rejects values outside of string enum.
+func strictEnumMethods(gname string, fieldNames []string) string {
+	var sb strings.Builder
+	sb.WriteString("\nfunc (e " + gname + ") valid() bool {\n")
+	sb.WriteString("\tswitch e {\n")
+	sb.WriteString("\tcase " + strings.Join(fieldNames, ", ") + ":\n")
+	sb.WriteString("\t\treturn true\n")
+	sb.WriteString("\t}\n")
+	sb.WriteString("\treturn false\n")
+	sb.WriteString("}\n\n")
 
+	sb.WriteString("func (e *" + gname + ") UnmarshalJSON(data []byte) error {\n")
+	sb.WriteString("\tvar s string\n")
+	sb.WriteString("\tif err := json.Unmarshal(data, &s); err != nil {\n")
+	sb.WriteString("\t\treturn err\n")
+	sb.WriteString("\t}\n")
+	sb.WriteString("\tif !" + gname + "(s).valid() {\n")
+	sb.WriteString("\t\treturn fmt.Errorf(\"unknown " + gname + " value: %q\", s)\n")
+	sb.WriteString("\t}\n")
+	sb.WriteString("\t*e = " + gname + "(s)\n")
+	sb.WriteString("\treturn nil\n")
 	sb.WriteString("}\n")
 	return sb.String()
 }
 
-func (g Generator) allofExtractFields(expr schema.ObjectExpr) map[string][]schema.ObjectExpr {
+func (g Generator) allofExtractFields(expr schema.ObjectExpr) (map[string][]schema.ObjectExpr, error) {
 	if !expr.IsAllOf {
-		panic("expr is not allof")
+		return nil, g.schemaErr(expr, errors.New("expr is not allOf"))
 	}
 	if len(expr.AllOf) == 0 {
-		panic("empty allof")
+		return nil, g.schemaErr(expr, errors.New("empty allOf"))
 	}
 
 	fields := make(map[string][]schema.ObjectExpr)
@@ -685,10 +4424,14 @@ func (g Generator) allofExtractFields(expr schema.ObjectExpr) map[string][]schem
 		if val.IsReference {
 			ref, err := val.Ref()
 			if err != nil {
-				panic(err)
+				return nil, g.schemaErr(val, err)
 			}
 			if ref.Expr.IsAllOf {
-				for name, allofFields := range g.allofExtractFields(ref.Expr) {
+				refFields, err := g.allofExtractFields(ref.Expr)
+				if err != nil {
+					return nil, err
+				}
+				for name, allofFields := range refFields {
 					tmp, ok := fields[name]
 					if !ok {
 						tmp = make([]schema.ObjectExpr, 0)
@@ -700,7 +4443,7 @@ func (g Generator) allofExtractFields(expr schema.ObjectExpr) map[string][]schem
 			}
 
 			if ref.Expr.IsReference {
-				panic("reference extr. unimplemented")
+				return nil, g.schemaErr(val, fmt.Errorf("allOf reference %s to reference is not supported", ref.Name))
 			}
 
 			for _, prop := range ref.Expr.Properties {
@@ -715,7 +4458,7 @@ func (g Generator) allofExtractFields(expr schema.ObjectExpr) map[string][]schem
 		}
 
 		if len(val.Properties) == 0 {
-			panic("allof no props")
+			return nil, g.schemaErr(val, errors.New("allOf item without properties"))
 		}
 		for _, prop := range val.Properties {
 			tmp, ok := fields[prop.Name]
@@ -726,12 +4469,19 @@ func (g Generator) allofExtractFields(expr schema.ObjectExpr) map[string][]schem
 			fields[prop.Name] = tmp
 		}
 	}
-	return fields
+	return fields, nil
 }
 
-func (g Generator) allofExprToGolang(expr schema.ObjectExpr) string {
+func (g Generator) allofExprToGolang(expr schema.ObjectExpr) (string, error) {
+	if singleRefAllOf(expr) {
+		return g.objectExprToGolang(expr.AllOf[0])
+	}
+
 	var sb strings.Builder
-	mergingFields := g.allofExtractFields(expr)
+	mergingFields, err := g.allofExtractFields(expr)
+	if err != nil {
+		return "", err
+	}
 	var keys []string
 	for name := range mergingFields {
 		keys = append(keys, name)
@@ -741,125 +4491,157 @@ func (g Generator) allofExprToGolang(expr schema.ObjectExpr) string {
 	for _, propName := range keys {
 		fields := mergingFields[propName]
 		if len(fields) == 0 {
-			panic("no fields")
-		}
-		if len(fields) == 1 {
-			sb.WriteString("\t" + g.goify(propName) + " " + g.objectExprToGolang(fields[0]) + "`json:\"" + propName + "\"`\n")
-			continue
+			return "", g.schemaErr(expr, fmt.Errorf("allOf property %s without fields", propName))
 		}
-		equal := true
-		for i := 1; i < len(fields); i++ {
-			if isDifferentExprs(fields[i-1], fields[i]) {
-				equal = false
-				break
-			}
+		equal, err := equalExprs(fields)
+		if err != nil {
+			return "", err
 		}
 		if equal {
-			sb.WriteString("\t" + g.goify(propName) + " " + g.objectExprToGolang(fields[0]) + "`json:\"" + propName + "\"`\n")
+			typ, err := g.objectExprToGolang(fields[0])
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString("\t" + g.goify(propName) + " " + typ + "`json:\"" + propName + "\"" + g.extraTags(propName) + "`\n")
 			continue
 		}
-		sb.WriteString("\t" + g.goify(propName) + " json.RawMessage `json:\"" + propName + "\"`\n")
+		if g.debug {
+			g.logMergeConflict(propName, fields)
+		}
+		sb.WriteString("\t" + g.goify(propName) + " json.RawMessage `json:\"" + propName + "\"" + g.extraTags(propName) + "`\n")
 	}
 
 	if sb.Len() == 0 {
-		panic("allof empty code")
+		return "", g.schemaErr(expr, errors.New("allOf without properties"))
 	}
 	sb.WriteString("}")
-	return sb.String()
+	return sb.String(), nil
+}
+
+// singleRefAllOf reports whether allOf consists of single reference, which
+// is used instead of copy of its fields.
+func singleRefAllOf(expr schema.ObjectExpr) bool {
+	return expr.IsAllOf && len(expr.AllOf) == 1 && expr.AllOf[0].IsReference
+}
+
+// equalExprs reports whether allOf branches define property by the same
+// expression, otherwise it is generated as json.RawMessage.
+func equalExprs(fields []schema.ObjectExpr) (bool, error) {
+	for i := 1; i < len(fields); i++ {
+		different, err := isDifferentExprs(fields[i-1], fields[i])
+		if different || err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// logMergeConflict logs Go types of allOf property which differ between
+// branches, so the property is generated as json.RawMessage.
+func (g Generator) logMergeConflict(propName string, fields []schema.ObjectExpr) {
+	// inline structs must not be registered only for the log
+	g.inline = nil
+	var types []string
+	for _, field := range fields {
+		typ, err := g.objectExprToGolang(field)
+		if err != nil {
+			typ = err.Error()
+		}
+		types = append(types, strings.Join(strings.Fields(typ), " "))
+	}
+	log.Printf("allOf property %q has different types in branches, using json.RawMessage: %s", propName, strings.Join(types, ", "))
 }
 
-func isDifferentExprs(expr1, expr2 schema.ObjectExpr) bool {
+func isDifferentExprs(expr1, expr2 schema.ObjectExpr) (bool, error) {
 	if expr1.Type != expr2.Type {
-		return true
+		return true, nil
 	}
 
 	if expr1.IsBaseType && expr2.IsBaseType {
-		return false
+		return false, nil
 	}
 
 	if expr1.IsReference && expr2.IsReference {
 		ref1, err := expr1.Ref()
 		if err != nil {
-			panic("gfg")
+			return false, err
 		}
 
 		ref2, err := expr2.Ref()
 		if err != nil {
-			panic("gfg2")
+			return false, err
 		}
 		return isDifferentDefs(ref1, ref2)
 	} else if expr1.IsReference && !expr2.IsReference ||
 		!expr1.IsReference && expr2.IsReference {
-		return true
+		return true, nil
 	}
 
 	if len(expr1.Properties) != len(expr2.Properties) {
-		return true
+		return true, nil
 	}
 	for i := 0; i < len(expr1.Properties); i++ {
 		p1 := expr1.Properties[i]
 		p2 := expr2.Properties[i]
-		if isDifferentDefs(p1, p2) {
-			return true
+		if different, err := isDifferentDefs(p1, p2); different || err != nil {
+			return different, err
 		}
 	}
 
 	if expr1.IsEnum && expr2.IsEnum {
 		if !testEqStrings(expr1.EnumNames, expr2.EnumNames) {
-			return true
+			return true, nil
 		}
 	} else if expr1.IsEnum && !expr2.IsEnum ||
 		!expr1.IsEnum && expr2.IsEnum {
-		return true
+		return true, nil
 	}
 
 	if expr1.IsAllOf && expr2.IsAllOf {
 		if len(expr1.AllOf) != len(expr2.AllOf) {
-			return true
+			return true, nil
 		}
 		for i := 0; i < len(expr1.AllOf); i++ {
 			a1 := expr1.AllOf[i]
 			a2 := expr2.AllOf[i]
-			if isDifferentExprs(a1, a2) {
-				return true
+			if different, err := isDifferentExprs(a1, a2); different || err != nil {
+				return different, err
 			}
 		}
 	} else if expr1.IsAllOf && !expr2.IsAllOf ||
 		!expr1.IsAllOf && expr2.IsAllOf {
-		return true
+		return true, nil
 	}
 
 	if expr1.IsOneOf && expr2.IsOneOf {
 		if len(expr1.OneOf) != len(expr2.OneOf) {
-			return true
+			return true, nil
 		}
 		for i := 0; i < len(expr1.OneOf); i++ {
 			a1 := expr1.OneOf[i]
 			a2 := expr2.OneOf[i]
-			if isDifferentExprs(a1, a2) {
-				return true
+			if different, err := isDifferentExprs(a1, a2); different || err != nil {
+				return different, err
 			}
 		}
 	} else if expr1.IsOneOf && !expr2.IsOneOf ||
 		!expr1.IsOneOf && expr2.IsOneOf {
-		return true
+		return true, nil
 	}
 
 	if expr1.ArrayOf != nil && expr2.ArrayOf != nil {
-		if isDifferentExprs(*expr1.ArrayOf, *expr2.ArrayOf) {
-			return true
-		}
+		return isDifferentExprs(*expr1.ArrayOf, *expr2.ArrayOf)
 	} else if expr1.ArrayOf != nil && expr2.ArrayOf == nil ||
 		expr1.ArrayOf == nil && expr2.ArrayOf != nil {
-		return true
+		return true, nil
 	}
 
-	return false
+	return false, nil
 }
 
-func isDifferentDefs(def1, def2 schema.ObjectDefinition) bool {
+func isDifferentDefs(def1, def2 schema.ObjectDefinition) (bool, error) {
 	if def1.Name != def2.Name {
-		return true
+		return true, nil
 	}
 	return isDifferentExprs(def1.Expr, def2.Expr)
 }
@@ -885,10 +4667,15 @@ func testEqStrings(a, b []string) bool {
 }
 
 var builtinTypes = map[string]struct{}{
+	"int":     {},
+	"int32":   {},
 	"int64":   {},
 	"float64": {},
 	"string":  {},
 	"bool":    {},
+	// used for numbers with -json-number, decoder handles only unnamed
+	// json.Number, so types must alias it
+	"json.Number": {},
 }
 
 func isBuiltin(s string) bool {
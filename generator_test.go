@@ -0,0 +1,2011 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Schemas of tests, methods and responses refer to objects.
+const (
+	testObjects = `{
+  "definitions": {
+    "base_bool_int": {"type": "integer", "enum": [0, 1], "enumNames": ["no", "yes"]},
+    "users_user": {
+      "type": "object",
+      "properties": {
+        "id": {"type": "integer"},
+        "first_name": {"type": "string"}
+      },
+      "required": ["id"]
+    }
+  }
+}`
+	testResponses = `{
+  "definitions": {
+    "users_get_response": {
+      "type": "object",
+      "properties": {
+        "response": {"type": "array", "items": {"$ref": "objects.json#/definitions/users_user"}}
+      }
+    },
+    "friends_get_response": {
+      "type": "object",
+      "properties": {
+        "response": {
+          "type": "object",
+          "properties": {
+            "count": {"type": "integer"},
+            "items": {"type": "array", "items": {"type": "integer"}}
+          }
+        }
+      }
+    },
+    "friends_get_extended_response": {
+      "type": "object",
+      "properties": {
+        "response": {
+          "type": "object",
+          "properties": {
+            "count": {"type": "integer"},
+            "items": {"type": "array", "items": {"$ref": "objects.json#/definitions/users_user"}}
+          }
+        }
+      }
+    }
+  }
+}`
+	testMethods = `{
+  "methods": [
+    {
+      "name": "users.get",
+      "parameters": [
+        {"name": "user_ids", "type": "array", "items": {"type": "string"}},
+        {"name": "count", "type": "integer", "minimum": 0}
+      ],
+      "responses": {
+        "response": {"$ref": "responses.json#/definitions/users_get_response"}
+      }
+    },
+    {
+      "name": "friends.get",
+      "parameters": [
+        {"name": "user_id", "type": "integer"},
+        {"name": "extended", "type": "boolean"}
+      ],
+      "responses": {
+        "response": {"$ref": "responses.json#/definitions/friends_get_response"},
+        "extendedResponse": {"$ref": "responses.json#/definitions/friends_get_extended_response"}
+      }
+    }
+  ]
+}`
+)
+
+// objectsWith returns objects schema of tests with additional definitions.
+func objectsWith(definitions string) string {
+	return strings.Replace(testObjects, `"definitions": {`, `"definitions": {`+definitions+`,`, 1)
+}
+
+// testSchemas are schemas generated by tests, empty ones are replaced by
+// schemas above.
+type testSchemas struct {
+	objects, responses, methods string
+}
+
+// runGenerator generates package from schemas with options in temporary
+// directory and returns generated files by paths relative to the package.
+func runGenerator(t *testing.T, opts Options, schemas testSchemas) (map[string]string, error) {
+	t.Helper()
+	if schemas.objects == "" {
+		schemas.objects = testObjects
+	}
+	if schemas.responses == "" {
+		schemas.responses = testResponses
+	}
+	if schemas.methods == "" {
+		schemas.methods = testMethods
+	}
+
+	dir, err := ioutil.TempDir("", "vkgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	for name, content := range map[string]string{
+		"objects.json":   schemas.objects,
+		"responses.json": schemas.responses,
+		"methods.json":   schemas.methods,
+	} {
+		if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := NewGenerator(opts, []byte(schemas.objects)).Generate(); err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]string)
+	err = filepath.Walk(pkgName, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(pkgName, path)
+		files[filepath.ToSlash(rel)] = string(src)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files, nil
+}
+
+// generateFiles is runGenerator failing test on errors.
+func generateFiles(t *testing.T, opts Options, schemas testSchemas) map[string]string {
+	t.Helper()
+	files, err := runGenerator(t, opts, schemas)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// clientStub declares types of vksdk client which generated code expects
+// to share package with. Like vksdk, requests get client token unless
+// params have one.
+const clientStub = `package generated
+
+import (
+	"context"
+	"encoding/json"
+)
+
+type Params map[string]interface{}
+
+type Response struct {
+	Response json.RawMessage
+}
+
+type VK struct {
+	AccessToken string
+	Handler     func(method string, params Params) (Response, error)
+}
+
+func (vk *VK) RequestUnmarshal(method string, params Params, obj interface{}) error {
+	copyParams := make(Params, len(params)+1)
+	for k, v := range params {
+		copyParams[k] = v
+	}
+	if _, ok := copyParams["access_token"]; !ok {
+		copyParams["access_token"] = vk.AccessToken
+	}
+	resp, err := vk.Handler(method, copyParams)
+	if err != nil || len(resp.Response) == 0 {
+		return err
+	}
+	return json.Unmarshal(resp.Response, obj)
+}
+
+func (vk *VK) RequestUnmarshalContext(ctx context.Context, method string, params Params, obj interface{}) error {
+	return vk.RequestUnmarshal(method, params, obj)
+}
+`
+
+// sdkStub declares Params of vksdk api package, which builders embed.
+const sdkStub = `package api
+
+type Params map[string]interface{}
+`
+
+// sdkErrorsStub declares error types of vksdk errors package.
+const sdkErrorsStub = `package errors
+
+type ErrorType int
+
+const NoType ErrorType = 0
+
+func GetType(err error) ErrorType { return 0 }
+`
+
+// sdkPackages returns stubs of vksdk packages by import paths.
+func sdkPackages(t *testing.T) map[string]*types.Package {
+	t.Helper()
+	return map[string]*types.Package{
+		DefaultSDKImport:             typeCheck(t, DefaultSDKImport, map[string]string{"api.go": sdkStub}, nil),
+		DefaultSDKImport + "/errors": typeCheck(t, DefaultSDKImport+"/errors", map[string]string{"errors.go": sdkErrorsStub}, nil),
+	}
+}
+
+// importerFunc imports packages of type-checked generated code.
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) {
+	return f(path)
+}
+
+// typeCheck type-checks sources of package by file names. Packages of
+// packages are imported before standard ones.
+func typeCheck(t *testing.T, path string, srcs map[string]string, packages map[string]*types.Package) *types.Package {
+	t.Helper()
+	pkg, err := checkPackage(path, srcs, packages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkg
+}
+
+// checkPackage is typeCheck returning errors.
+func checkPackage(path string, srcs map[string]string, packages map[string]*types.Package) (*types.Package, error) {
+	fset := token.NewFileSet()
+	var names []string
+	for name := range srcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var files []*ast.File
+	for _, name := range names {
+		file, err := parser.ParseFile(fset, name, srcs[name], 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	std := importer.Default()
+	conf := types.Config{
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			if pkg, ok := packages[path]; ok {
+				return pkg, nil
+			}
+			return std.Import(path)
+		}),
+	}
+	return conf.Check(path, fset, files, nil)
+}
+
+// generatedPackage returns Go files of generated package with client stub,
+// test files and files of subpackages are skipped.
+func generatedPackage(files map[string]string) map[string]string {
+	srcs := map[string]string{"stub.go": clientStub}
+	for name, src := range files {
+		if strings.Contains(name, "/") || strings.HasSuffix(name, "_test.go") || !strings.HasSuffix(name, ".go") {
+			continue
+		}
+		srcs[name] = src
+	}
+	return srcs
+}
+
+// assertContains fails test if generated file lacks any of snippets.
+func assertContains(t *testing.T, files map[string]string, name string, snippets ...string) {
+	t.Helper()
+	src, ok := files[name]
+	if !ok {
+		t.Fatalf("%s is not generated", name)
+	}
+	for _, snippet := range snippets {
+		if !strings.Contains(src, snippet) {
+			t.Errorf("%s does not contain %q:\n%s", name, snippet, src)
+		}
+	}
+}
+
+// assertNotContains fails test if generated file has any of snippets.
+func assertNotContains(t *testing.T, files map[string]string, name string, snippets ...string) {
+	t.Helper()
+	src := files[name]
+	for _, snippet := range snippets {
+		if strings.Contains(src, snippet) {
+			t.Errorf("%s contains %q:\n%s", name, snippet, src)
+		}
+	}
+}
+
+// goTest runs tests of generated sources with client stub in temporary
+// module, which requires vksdk version of generator module.
+func goTest(t *testing.T, srcs map[string]string) {
+	t.Helper()
+	if out, err := runGoTest(t, "1.14", srcs); err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+}
+
+// runGoTest is goTest with go version of temporary module returning output
+// and error of go test.
+func runGoTest(t *testing.T, goVersion string, srcs map[string]string) ([]byte, error) {
+	t.Helper()
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command is not found")
+	}
+	sum, err := ioutil.ReadFile("go.sum")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "vkgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"go.mod":             "module vkgentest\n\ngo " + goVersion + "\n\nrequire github.com/SevereCloud/vksdk v1.10.0\n",
+		"go.sum":             string(sum),
+		pkgName + "/stub.go": clientStub,
+	}
+	for name, src := range srcs {
+		files[pkgName+"/"+name] = src
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command(gobin, "test", "./...")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off")
+	return cmd.CombinedOutput()
+}
+
+func TestGeneratePackageTypeChecks(t *testing.T) {
+	files := generateFiles(t, Options{}, testSchemas{})
+	typeCheck(t, pkgName, generatedPackage(files), sdkPackages(t))
+}
+
+func TestGenerateSplitTypeChecks(t *testing.T) {
+	const path = "example.com/vk/generated"
+	files := generateFiles(t, Options{Split: path}, testSchemas{})
+	if _, ok := files["methods.gen.go"]; ok {
+		t.Error("methods.gen.go is generated in split mode")
+	}
+
+	packages := sdkPackages(t)
+	packages[path] = typeCheck(t, path, generatedPackage(files), packages)
+	namespaces := make(map[string]map[string]string)
+	for name, src := range files {
+		if i := strings.Index(name, "/"); i >= 0 {
+			if namespaces[name[:i]] == nil {
+				namespaces[name[:i]] = make(map[string]string)
+			}
+			namespaces[name[:i]][name[i+1:]] = src
+		}
+	}
+	if len(namespaces) != 2 {
+		t.Errorf("packages of namespaces: %v", namespaces)
+	}
+	for namespace, srcs := range namespaces {
+		typeCheck(t, path+"/"+namespace, srcs, packages)
+	}
+}
+
+func TestBuilderExecuteExtended(t *testing.T) {
+	files := generateFiles(t, Options{}, testSchemas{})
+	srcs := generatedPackage(files)
+	delete(srcs, "stub.go")
+	srcs["builders_test.go"] = `package generated
+
+import "testing"
+
+func TestFriendsGetBuilder(t *testing.T) {
+	var sent Params
+	vk := &VK{Handler: func(method string, params Params) (Response, error) {
+		sent = params
+		return Response{Response: []byte(` + "`" + `{"count":1,"items":[{"id":1}]}` + "`" + `)}, nil
+	}}
+
+	b := NewFriendsGetBuilder().UserID(1)
+	response, err := b.ExecuteExtended(vk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Items) != 1 || response.Items[0].ID != 1 {
+		t.Errorf("response %+v", response)
+	}
+	if sent[paramExtended] != true || sent["user_id"] != b.Params["user_id"] {
+		t.Errorf("sent params %v", sent)
+	}
+	if _, ok := b.Params[paramExtended]; ok {
+		t.Errorf("ExecuteExtended changed builder params %v", b.Params)
+	}
+}
+`
+	goTest(t, srcs)
+}
+
+func TestBooleanParams(t *testing.T) {
+	files := generateFiles(t, Options{}, testSchemas{})
+	srcs := generatedPackage(files)
+	delete(srcs, "stub.go")
+	srcs["params_test.go"] = `package generated
+
+import "testing"
+
+func TestBooleanParams(t *testing.T) {
+	if v := NewFriendsGetBuilder().Extended(true).Params["extended"]; v != 1 {
+		t.Errorf("builder stores true as %v", v)
+	}
+	if v := NewFriendsGetBuilder().Extended(false).Params["extended"]; v != 0 {
+		t.Errorf("builder stores false as %v", v)
+	}
+
+	params, err := FriendsGet{Extended: true}.params()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := params["extended"]; v != 1 {
+		t.Errorf("request sends true as %v", v)
+	}
+}
+`
+	goTest(t, srcs)
+}
+
+func TestWithTokenProvider(t *testing.T) {
+	files := generateFiles(t, Options{PerCallToken: true}, testSchemas{})
+	srcs := generatedPackage(files)
+	delete(srcs, "stub.go")
+	srcs["client_test.go"] = `package generated
+
+import "testing"
+
+func TestWithTokenProvider(t *testing.T) {
+	var token interface{}
+	vk := &VK{
+		AccessToken: "client",
+		Handler: func(method string, params Params) (Response, error) {
+			token = params["access_token"]
+			return Response{}, nil
+		},
+	}
+	vk.WithTokenProvider(func() string { return "provided" })
+
+	if _, err := NewFriendsGetBuilder().Execute(vk); err != nil {
+		t.Fatal(err)
+	}
+	if token != "provided" {
+		t.Errorf("request without token sent %v", token)
+	}
+
+	if _, err := NewFriendsGetBuilder().AccessToken("call").Execute(vk); err != nil {
+		t.Fatal(err)
+	}
+	if token != "call" {
+		t.Errorf("request with per-call token sent %v", token)
+	}
+}
+`
+	goTest(t, srcs)
+}
+
+func TestErrorPredicates(t *testing.T) {
+	for _, wrap := range []bool{false, true} {
+		files := generateFiles(t, Options{WrapErrors: wrap}, testSchemas{})
+		typeCheck(t, pkgName, generatedPackage(files), sdkPackages(t))
+
+		wrapped := "vkerrors.TooMany.New(\"too many requests\")"
+		if wrap {
+			wrapped = "WrapError(\"users.get\", " + wrapped + ")"
+		}
+		goTest(t, map[string]string{
+			"errors.gen.go": files["errors.gen.go"],
+			"errors_test.go": `package generated
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	vkerrors "github.com/SevereCloud/vksdk/api/errors"
+)
+
+func TestErrorPredicates(t *testing.T) {
+	err := fmt.Errorf("friends: %w", ` + wrapped + `)
+	if !IsRateLimited(err) || IsTokenInvalid(err) {
+		t.Errorf("predicates of %v", err)
+	}
+	if err := errors.New("network"); IsRateLimited(err) || IsTokenInvalid(err) {
+		t.Errorf("predicates of %v", err)
+	}
+}
+`,
+		})
+		if !wrap {
+			continue
+		}
+
+		srcs := generatedPackage(files)
+		delete(srcs, "stub.go")
+		srcs["builders_test.go"] = `package generated
+
+import (
+	"errors"
+	"testing"
+
+	vkerrors "github.com/SevereCloud/vksdk/api/errors"
+)
+
+func TestBuilderWrapError(t *testing.T) {
+	vk := &VK{Handler: func(method string, params Params) (Response, error) {
+		return Response{}, vkerrors.TooMany.New("too many requests")
+	}}
+	_, err := NewFriendsGetBuilder().UserID(1).Execute(vk)
+	var vkErr *VKError
+	if !errors.As(err, &vkErr) || vkErr.Method != "friends.get" || !IsRateLimited(err) {
+		t.Errorf("error of builder %v", err)
+	}
+}
+`
+		goTest(t, srcs)
+	}
+}
+
+func TestExtendedAppendCopies(t *testing.T) {
+	responses := `{
+  "definitions": {
+    "friends_get_extended_response": {
+      "type": "object",
+      "properties": {
+        "response": {
+          "type": "object",
+          "properties": {
+            "items": {"type": "array", "items": {"type": "integer"}},
+            "profiles": {"type": "array", "items": {"$ref": "objects.json#/definitions/users_user"}}
+          }
+        }
+      }
+    }
+  }
+}`
+	methods := `{
+  "methods": [
+    {
+      "name": "friends.get",
+      "responses": {
+        "extendedResponse": {"$ref": "responses.json#/definitions/friends_get_extended_response"}
+      }
+    }
+  ]
+}`
+	files := generateFiles(t, Options{ExtendedMerge: true}, testSchemas{responses: responses, methods: methods})
+	goTest(t, map[string]string{
+		"objects.gen.go":   files["objects.gen.go"],
+		"responses.gen.go": files["responses.gen.go"],
+		"responses_test.go": `package generated
+
+import "testing"
+
+func TestAppend(t *testing.T) {
+	var a, b FriendsGetExtendedResponse
+	a.Items = append(a.Items, 1, 2)[:1]
+	a.Profiles = append(a.Profiles, UsersUser{ID: 1}, UsersUser{ID: 2})[:1]
+	b.Items = append(b.Items, 3)
+	b.Profiles = append(b.Profiles, UsersUser{ID: 1}, UsersUser{ID: 3})
+
+	merged := a.Append(b)
+	if a.Items[:2][1] != 2 || a.Profiles[:2][1].ID != 2 {
+		t.Error("Append wrote into backing arrays of receiver")
+	}
+	if len(merged.Items) != 2 || merged.Items[1] != 3 {
+		t.Errorf("merged items %v", merged.Items)
+	}
+	if len(merged.Profiles) != 2 || merged.Profiles[1].ID != 3 {
+		t.Errorf("merged profiles %v", merged.Profiles)
+	}
+}
+`,
+	})
+}
+
+func TestPostfixRules(t *testing.T) {
+	methods := `{
+  "methods": [
+    {
+      "name": "storage.get",
+      "responses": {
+        "response": {"$ref": "responses.json#/definitions/users_get_response"},
+        "keysResponse": {"$ref": "responses.json#/definitions/users_get_response"}
+      }
+    },
+    {
+      "name": "friends.getOnline",
+      "responses": {
+        "response": {"$ref": "responses.json#/definitions/users_get_response"},
+        "onlineMobileResponse": {"$ref": "responses.json#/definitions/users_get_response"}
+      }
+    }
+  ]
+}`
+	files := generateFiles(t, Options{}, testSchemas{methods: methods})
+	assertContains(t, files, "methods.gen.go",
+		"func (vk *VK) StorageGetWithKeys(",
+		"func (vk *VK) FriendsGetOnlineOnlineMobile(",
+	)
+	assertNotContains(t, files, "methods.gen.go", "StorageGetKeys", "FriendsGetOnlineMobile(")
+}
+
+func TestGenerateUnresolvedReference(t *testing.T) {
+	objects := `{
+  "definitions": {
+    "users_user": {
+      "type": "object",
+      "properties": {
+        "friend": {"$ref": "objects.json#/definitions/users_missing"}
+      }
+    }
+  }
+}`
+	_, err := runGenerator(t, Options{}, testSchemas{objects: objects})
+	if err == nil {
+		t.Fatal("no error for unresolved reference")
+	}
+	want := "objects.json: users_user: friend: objects.json#/definitions/users_missing: definition not found"
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("error %q does not contain %q", err, want)
+	}
+}
+
+func TestGenerateAllOfWithoutProperties(t *testing.T) {
+	objects := `{
+  "definitions": {
+    "base_object": {"allOf": [{"type": "object"}, {"type": "object"}]}
+  }
+}`
+	_, err := runGenerator(t, Options{}, testSchemas{objects: objects})
+	if err == nil || !strings.Contains(err.Error(), "base_object: allOf item without properties") {
+		t.Errorf("error of allOf without properties: %v", err)
+	}
+}
+
+func TestMergedOneOfRoundTrip(t *testing.T) {
+	objects := `{
+  "definitions": {
+    "users_user": {
+      "type": "object",
+      "properties": {
+        "id": {"type": "integer"},
+        "first_name": {"type": "string"}
+      }
+    },
+    "groups_group": {
+      "type": "object",
+      "properties": {
+        "id": {"type": "integer"},
+        "name": {"type": "string"},
+        "screen_name": {"type": "string"}
+      }
+    },
+    "users_subscriptions_item": {
+      "type": "object",
+      "oneOf": [
+        {"$ref": "objects.json#/definitions/users_user"},
+        {"$ref": "objects.json#/definitions/groups_group"}
+      ]
+    }
+  }
+}`
+	files := generateFiles(t, Options{}, testSchemas{objects: objects})
+
+	goTest(t, map[string]string{
+		"objects.gen.go": files["objects.gen.go"],
+		"objects_test.go": `package generated
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestUsersSubscriptionsItem(t *testing.T) {
+	for payload, group := range map[string]bool{
+		` + "`" + `{"id":1,"first_name":"Pavel"}` + "`" + `:            false,
+		` + "`" + `{"id":1,"name":"VK","screen_name":"vk"}` + "`" + `: true,
+		` + "`" + `{"id":1}` + "`" + `:                                false,
+	} {
+		var item UsersSubscriptionsItem
+		if err := json.Unmarshal([]byte(payload), &item); err != nil {
+			t.Fatal(err)
+		}
+		if (item.GroupsGroup != nil) != group || (item.UsersUser != nil) == group {
+			t.Errorf("%s: unmarshaled into %+v", payload, item)
+		}
+		data, err := json.Marshal(item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var again UsersSubscriptionsItem
+		if err := json.Unmarshal(data, &again); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(item, again) {
+			t.Errorf("%s changed by round trip: %s", payload, data)
+		}
+	}
+
+	var item UsersSubscriptionsItem
+	if err := json.Unmarshal([]byte("1"), &item); err == nil {
+		t.Error("number unmarshaled into oneOf of objects")
+	}
+}
+`,
+	})
+}
+
+func TestNogoifyKeywords(t *testing.T) {
+	methods := `{
+  "methods": [
+    {
+      "name": "search",
+      "parameters": [
+        {"name": "type", "type": "string"},
+        {"name": "count", "type": "integer"}
+      ],
+      "responses": {
+        "response": {"$ref": "responses.json#/definitions/users_get_response"}
+      }
+    }
+  ]
+}`
+	files := generateFiles(t, Options{Nogoify: true}, testSchemas{methods: methods})
+	assertContains(t, files, "requests.gen.go",
+		"\ttype_ string\n",
+		"\t\tparams[\"type\"] = req.type_\n",
+	)
+	assertContains(t, files, "builders.gen.go", "func (b *searchBuilder) type_(v string) *searchBuilder {\n")
+	typeCheck(t, pkgName, generatedPackage(files), sdkPackages(t))
+}
+
+func TestGoifyEmptyName(t *testing.T) {
+	g := NewGenerator(Options{}, []byte(testObjects))
+	for _, name := range []string{"", " ", "\t"} {
+		if got := g.goify(name); got != emptyName {
+			t.Errorf("goify(%q) = %q, want %q", name, got, emptyName)
+		}
+	}
+	if got := g.goify("user_ids"); got != "UserIDs" {
+		t.Errorf("goify(%q) = %q", "user_ids", got)
+	}
+
+	objects := objectsWith(`
+    "base_sex": {"type": "integer", "enum": [0, 1, 2], "enumNames": ["", "female", "male"]}`)
+	files := generateFiles(t, Options{}, testSchemas{objects: objects})
+	assertContains(t, files, "objects.gen.go", "case BaseSex"+emptyName+":", "case BaseSexFemale:")
+}
+
+func TestEmptyObjects(t *testing.T) {
+	objects := objectsWith(`
+    "base_empty": {}`)
+	for policy, want := range map[string]string{
+		"":                    "type BaseEmpty struct {\n}",
+		EmptyObjectStruct:     "type BaseEmpty struct {\n}",
+		EmptyObjectRawMessage: "type BaseEmpty json.RawMessage",
+		EmptyObjectAny:        "type BaseEmpty interface{}",
+	} {
+		files := generateFiles(t, Options{EmptyObjects: policy}, testSchemas{objects: objects})
+		assertContains(t, files, "objects.gen.go", want)
+	}
+}
+
+func TestTokenProviderRotation(t *testing.T) {
+	files := generateFiles(t, Options{}, testSchemas{})
+	srcs := generatedPackage(files)
+	delete(srcs, "stub.go")
+	srcs["client_test.go"] = `package generated
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestTokenProviderRotation(t *testing.T) {
+	var tokens []interface{}
+	vk := &VK{
+		Handler: func(method string, params Params) (Response, error) {
+			tokens = append(tokens, params["access_token"])
+			return Response{}, nil
+		},
+	}
+	n := 0
+	vk.WithTokenProvider(func() string {
+		n++
+		return "token" + strconv.Itoa(n)
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := vk.UsersGet(Params{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := vk.UsersGet(Params{"access_token": "explicit"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []interface{}{"token1", "token2", "explicit"}; !reflect.DeepEqual(tokens, want) {
+		t.Errorf("sent tokens %v, want %v", tokens, want)
+	}
+}
+`
+	goTest(t, srcs)
+}
+
+func TestComparable(t *testing.T) {
+	objects := objectsWith(`
+    "users_key": {
+      "type": "object",
+      "properties": {
+        "ids": {"type": "array", "items": {"type": "integer"}}
+      }
+    }`)
+	files := generateFiles(t, Options{Comparable: []string{"UsersUser", "BaseBoolInt"}}, testSchemas{objects: objects})
+	assertContains(t, files, "comparable.gen.go", "_ = map[UsersUser]struct{}{}")
+	typeCheck(t, pkgName, generatedPackage(files), sdkPackages(t))
+
+	files = generateFiles(t, Options{Comparable: []string{"UsersKey"}}, testSchemas{objects: objects})
+	_, err := checkPackage(pkgName, generatedPackage(files), sdkPackages(t))
+	if err == nil || !strings.Contains(err.Error(), "invalid map key type UsersKey") {
+		t.Errorf("error of type with slice field: %v", err)
+	}
+}
+
+func TestDefaultTags(t *testing.T) {
+	objects := objectsWith(`
+    "friends_list": {
+      "type": "object",
+      "properties": {
+        "count": {"type": "integer", "default": 50},
+        "online": {"type": "boolean", "default": 1}
+      }
+    }`)
+	methods := `{
+  "methods": [
+    {
+      "name": "users.get",
+      "parameters": [
+        {"name": "count", "type": "integer", "default": 50}
+      ],
+      "responses": {
+        "response": {"$ref": "responses.json#/definitions/users_get_response"}
+      }
+    }
+  ]
+}`
+	schemas := testSchemas{objects: objects, methods: methods}
+	files := generateFiles(t, Options{DefaultTags: true}, schemas)
+	assertContains(t, files, "objects.gen.go",
+		"`json:\"count\" default:\"50\"`",
+		"`json:\"online\" default:\"true\"`",
+	)
+	assertContains(t, files, "requests.gen.go", "Count int64 `default:\"50\"`")
+
+	files = generateFiles(t, Options{}, schemas)
+	assertNotContains(t, files, "objects.gen.go", "default:")
+}
+
+func TestChunkedMethod(t *testing.T) {
+	methods := `{
+  "methods": [
+    {
+      "name": "users.get",
+      "parameters": [
+        {"name": "user_ids", "type": "array", "items": {"type": "integer"}, "maxItems": 2}
+      ],
+      "responses": {
+        "response": {"$ref": "responses.json#/definitions/users_get_response"}
+      }
+    }
+  ]
+}`
+	files := generateFiles(t, Options{}, testSchemas{methods: methods})
+	srcs := generatedPackage(files)
+	delete(srcs, "stub.go")
+	srcs["methods_test.go"] = `package generated
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestUsersGetChunked(t *testing.T) {
+	var chunks [][]int64
+	vk := &VK{Handler: func(method string, params Params) (Response, error) {
+		ids := params["user_ids"].([]int64)
+		chunks = append(chunks, ids)
+		var users []UsersUser
+		for _, id := range ids {
+			users = append(users, UsersUser{ID: id})
+		}
+		data, err := json.Marshal(users)
+		return Response{Response: data}, err
+	}}
+
+	response, err := vk.UsersGetChunked([]int64{1, 2, 3, 4, 5}, Params{"fields": "photo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 3 || len(chunks[2]) != 1 {
+		t.Errorf("chunks %v", chunks)
+	}
+	if len(response) != 5 || response[4].ID != 5 {
+		t.Errorf("merged response %+v", response)
+	}
+}
+`
+	goTest(t, srcs)
+}
+
+func TestInterfaces(t *testing.T) {
+	objects := objectsWith(`
+    "groups_group": {
+      "type": "object",
+      "properties": {
+        "id": {"type": "integer"},
+        "name": {"type": "string"}
+      }
+    }`)
+	ifaces := Interfaces{"Owner": {
+		Method:  "OwnerID() int64",
+		Field:   "ID",
+		Objects: []string{"UsersUser", "GroupsGroup"},
+	}}
+	files := generateFiles(t, Options{Interfaces: ifaces}, testSchemas{objects: objects})
+	assertContains(t, files, "objects.gen.go",
+		"type Owner interface {\n\tOwnerID() int64\n}",
+		"func (o *GroupsGroup) OwnerID() int64 {\n\treturn o.ID\n}",
+	)
+
+	srcs := generatedPackage(files)
+	srcs["owner.go"] = `package generated
+
+var (
+	_ Owner = &UsersUser{}
+	_ Owner = &GroupsGroup{}
+)
+`
+	typeCheck(t, pkgName, srcs, sdkPackages(t))
+}
+
+func TestValidateNested(t *testing.T) {
+	objects := objectsWith(`
+    "messages_keyboard": {
+      "type": "object",
+      "properties": {
+        "buttons": {"type": "array", "items": {"type": "array", "items": {"$ref": "objects.json#/definitions/messages_keyboard_button"}}},
+        "one_time": {"type": "boolean"}
+      },
+      "required": ["buttons"]
+    },
+    "messages_keyboard_button": {
+      "type": "object",
+      "properties": {
+        "action": {"$ref": "objects.json#/definitions/messages_keyboard_button_action"},
+        "next": {"$ref": "objects.json#/definitions/messages_keyboard_button"}
+      }
+    },
+    "messages_keyboard_button_action": {
+      "type": "object",
+      "properties": {
+        "label": {"type": "string", "maxLength": 5},
+        "app_id": {"type": "integer", "minimum": 1}
+      }
+    }`)
+	methods := `{
+  "methods": [
+    {
+      "name": "messages.send",
+      "parameters": [
+        {"name": "message", "type": "string", "maxLength": 10},
+        {"name": "keyboard", "$ref": "objects.json#/definitions/messages_keyboard"}
+      ],
+      "responses": {
+        "response": {"$ref": "responses.json#/definitions/users_get_response"}
+      }
+    }
+  ]
+}`
+	files := generateFiles(t, Options{}, testSchemas{objects: objects, methods: methods})
+	assertContains(t, files, "requests.gen.go",
+		"\tif err := validateNested(\"keyboard\", req.Keyboard, depth); err != nil {\n")
+	assertContains(t, files, "validate.gen.go",
+		"func (obj MessagesKeyboardButtonAction) validate(depth int) error {\n")
+
+	srcs := generatedPackage(files)
+	delete(srcs, "stub.go")
+	// builders refer to objects of SDK
+	delete(srcs, "builders.gen.go")
+	srcs["validate_test.go"] = `package generated
+
+import "testing"
+
+func TestValidateNested(t *testing.T) {
+	req := MessagesSend{Keyboard: &MessagesKeyboard{Buttons: [][]MessagesKeyboardButton{
+		{{Action: MessagesKeyboardButtonAction{Label: "ok"}}},
+		{{}, {Action: MessagesKeyboardButtonAction{Label: "cancel"}}},
+	}}}
+	err := req.Validate()
+	if err == nil || err.Error() != "messages.send: keyboard.buttons[1][1].action.label must be at most 5 characters" {
+		t.Errorf("error of nested field: %v", err)
+	}
+
+	req = MessagesSend{Message: "long message", Keyboard: &MessagesKeyboard{}}
+	if err := req.Validate(); err == nil || err.Error() != "messages.send: message must be at most 10 characters" {
+		t.Errorf("error of parameter: %v", err)
+	}
+
+	cycle := &MessagesKeyboardButton{}
+	cycle.Next = cycle
+	req = MessagesSend{Keyboard: &MessagesKeyboard{Buttons: [][]MessagesKeyboardButton{{*cycle}}}}
+	if err := req.Validate(); err == nil {
+		t.Error("no error for cyclic value")
+	}
+
+	req = MessagesSend{Keyboard: &MessagesKeyboard{Buttons: [][]MessagesKeyboardButton{{{Action: MessagesKeyboardButtonAction{AppID: 1}}}}}}
+	if err := req.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+`
+	goTest(t, srcs)
+
+	// requests without constrained objects don't need the helper
+	files = generateFiles(t, Options{}, testSchemas{})
+	if _, ok := files["validate.gen.go"]; ok {
+		t.Error("validate.gen.go generated without constrained objects")
+	}
+}
+
+func TestBitmasks(t *testing.T) {
+	objects := objectsWith(`
+    "account_push_settings": {
+      "type": "object",
+      "properties": {
+        "notify": {"type": "integer"}
+      }
+    }`)
+	bitmasks := Bitmasks{"AccountPushSettings.Notify": {
+		Type:  "NotifyFlags",
+		Flags: map[string]int64{"Messages": 1, "Wall": 2, "Photos": 4},
+	}}
+	files := generateFiles(t, Options{Bitmasks: bitmasks}, testSchemas{objects: objects})
+	goTest(t, map[string]string{
+		"objects.gen.go": files["objects.gen.go"],
+		"objects_test.go": `package generated
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNotifyFlags(t *testing.T) {
+	var settings AccountPushSettings
+	if err := json.Unmarshal([]byte(` + "`" + `{"notify": 5}` + "`" + `), &settings); err != nil {
+		t.Fatal(err)
+	}
+	if !settings.Notify.Has(NotifyFlagsMessages|NotifyFlagsPhotos) || settings.Notify.Has(NotifyFlagsWall) {
+		t.Errorf("flags %b", settings.Notify)
+	}
+	if flags := settings.Notify.Set(NotifyFlagsWall); flags != 7 {
+		t.Errorf("flags with wall set %b", flags)
+	}
+}
+`,
+	})
+}
+
+func TestPreserveUnknown(t *testing.T) {
+	files := generateFiles(t, Options{PreserveUnknown: true}, testSchemas{})
+	goTest(t, map[string]string{
+		"objects.gen.go": files["objects.gen.go"],
+		"objects_test.go": `package generated
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestUnknownFields(t *testing.T) {
+	payload := []byte(` + "`" + `{"id": 1, "first_name": "Pavel", "is_closed": true}` + "`" + `)
+	var user UsersUser
+	if err := json.Unmarshal(payload, &user); err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != 1 || user.FirstName != "Pavel" {
+		t.Errorf("known fields %+v", user)
+	}
+
+	data, err := json.Marshal(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want, got map[string]interface{}
+	if err := json.Unmarshal(payload, &want); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip of %s gives %s", payload, data)
+	}
+}
+`,
+	})
+}
+
+func TestMergedOneOfPreserveUnknown(t *testing.T) {
+	objects := objectsWith(`
+    "base_a": {
+      "type": "object",
+      "properties": {
+        "id": {"type": "integer"}
+      },
+      "required": ["id"]
+    },
+    "wall_att": {
+      "type": "object",
+      "oneOf": [
+        {"$ref": "objects.json#/definitions/base_a"},
+        {"type": "object", "properties": {"x": {"type": "integer"}}}
+      ]
+    }`)
+	files := generateFiles(t, Options{PreserveUnknown: true}, testSchemas{objects: objects})
+	goTest(t, map[string]string{
+		"objects.gen.go": files["objects.gen.go"],
+		"objects_test.go": `package generated
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestWallAtt(t *testing.T) {
+	var att WallAtt
+	if err := json.Unmarshal([]byte(` + "`" + `{"x": 1}` + "`" + `), &att); err != nil {
+		t.Fatal(err)
+	}
+	if att.BaseA != nil || att.X == nil || *att.X != 1 {
+		t.Errorf("inline branch isn't selected: %+v", att)
+	}
+	if data, err := json.Marshal(att); err != nil || string(data) != ` + "`" + `{"x":1}` + "`" + ` {
+		t.Errorf("round trip gives %s, %v", data, err)
+	}
+
+	att = WallAtt{}
+	if err := json.Unmarshal([]byte(` + "`" + `{"id": 1, "y": 2}` + "`" + `), &att); err != nil {
+		t.Fatal(err)
+	}
+	if att.BaseA == nil || att.BaseA.ID != 1 {
+		t.Errorf("referenced branch isn't selected: %+v", att)
+	}
+	if data, err := json.Marshal(att); err != nil || string(data) != ` + "`" + `{"id":1,"y":2}` + "`" + ` {
+		t.Errorf("round trip gives %s, %v", data, err)
+	}
+}
+`,
+	})
+}
+
+func TestObjectRequiredFields(t *testing.T) {
+	objects := objectsWith(`
+    "groups_group": {
+      "type": "object",
+      "properties": {
+        "id": {"type": "integer"},
+        "admin": {"$ref": "objects.json#/definitions/users_user"},
+        "name": {"type": "string"}
+      },
+      "required": ["id", "name"]
+    },
+    "groups_group_min": {
+      "type": "object",
+      "properties": {
+        "id": {"type": "integer"},
+        "admin": {"$ref": "objects.json#/definitions/users_user"}
+      }
+    }`)
+	files := generateFiles(t, Options{}, testSchemas{objects: objects})
+	assertContains(t, files, "objects.gen.go",
+		"type GroupsGroup struct {\n\tID    int64      `json:\"id\"`\n\tAdmin *UsersUser `json:\"admin,omitempty\"`\n\tName  string     `json:\"name\"`\n}",
+		// all fields are required without required list
+		"type GroupsGroupMin struct {\n\tID    int64     `json:\"id\"`\n\tAdmin UsersUser `json:\"admin\"`\n}",
+		"FirstName string `json:\"first_name,omitempty\"`",
+	)
+}
+
+func TestTypeSafeExtended(t *testing.T) {
+	methods := `{
+  "methods": [
+    {
+      "name": "friends.get",
+      "parameters": [
+        {"name": "extended", "type": "boolean"}
+      ],
+      "responses": {
+        "response": {"$ref": "responses.json#/definitions/friends_get_response"},
+        "extendedResponse": {"$ref": "responses.json#/definitions/friends_get_extended_response"}
+      }
+    },
+    {
+      "name": "wall.get",
+      "responses": {
+        "response": {"$ref": "responses.json#/definitions/friends_get_response"},
+        "extendedResponse": {"$ref": "responses.json#/definitions/friends_get_extended_response"}
+      }
+    }
+  ]
+}`
+	files := generateFiles(t, Options{}, testSchemas{methods: methods})
+	srcs := generatedPackage(files)
+	delete(srcs, "stub.go")
+	srcs["methods_test.go"] = `package generated
+
+import "testing"
+
+func TestExtendedSafe(t *testing.T) {
+	var sent Params
+	vk := &VK{Handler: func(method string, params Params) (Response, error) {
+		sent = params
+		return Response{}, nil
+	}}
+
+	if _, err := vk.FriendsGetExtendedSafe(FriendsGet{}); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := sent["extended"]; ok {
+		t.Errorf("extended parameter of request is overridden by %v", v)
+	}
+	if _, err := vk.FriendsGetExtendedSafe(FriendsGet{Extended: true}); err != nil {
+		t.Fatal(err)
+	}
+	if v := sent["extended"]; v != 1 {
+		t.Errorf("extended parameter of request is sent as %v", v)
+	}
+
+	if _, err := vk.WallGetExtendedSafe(WallGet{}); err != nil {
+		t.Fatal(err)
+	}
+	if v := sent["extended"]; v != true {
+		t.Errorf("extended is not requested by method without parameter: %v", v)
+	}
+}
+`
+	goTest(t, srcs)
+}
+
+func TestEnumIntBacked(t *testing.T) {
+	objects := objectsWith(`
+    "base_name_case": {"type": "string", "enum": ["nom", "gen"], "enumNames": ["nominative", "genitive"]}`)
+	files := generateFiles(t, Options{EnumIntBacked: true}, testSchemas{objects: objects})
+	assertContains(t, files, "objects.gen.go", "type BaseNameCase int\n")
+	goTest(t, map[string]string{
+		"objects.gen.go": files["objects.gen.go"],
+		"objects_test.go": `package generated
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestBaseNameCase(t *testing.T) {
+	var v struct {
+		NameCase BaseNameCase ` + "`" + `json:"name_case"` + "`" + `
+	}
+	if err := json.Unmarshal([]byte(` + "`" + `{"name_case": "gen"}` + "`" + `), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.NameCase != BaseNameCaseGenitive {
+		t.Errorf("unmarshaled %d", v.NameCase)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != ` + "`" + `{"name_case":"gen"}` + "`" + ` {
+		t.Errorf("marshaled %s", data)
+	}
+
+	if err := json.Unmarshal([]byte(` + "`" + `{"name_case": "abl"}` + "`" + `), &v); err == nil {
+		t.Error("unknown value is unmarshaled")
+	}
+}
+`,
+	})
+}
+
+func TestParamsApplier(t *testing.T) {
+	files := generateFiles(t, Options{}, testSchemas{})
+	srcs := generatedPackage(files)
+	delete(srcs, "stub.go")
+	srcs["requests_test.go"] = `package generated
+
+import "testing"
+
+func TestParamsApplier(t *testing.T) {
+	var applier ParamsApplier = UsersGet{Count: 5}
+	params, err := applier.params()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(params) != 1 || params["count"] != int64(5) {
+		t.Errorf("params %v", params)
+	}
+}
+`
+	goTest(t, srcs)
+
+	files = generateFiles(t, Options{LenientParams: true}, testSchemas{})
+	assertContains(t, files, "requests.gen.go", "type ParamsApplier interface {\n\tparams() Params\n}")
+	typeCheck(t, pkgName, generatedPackage(files), sdkPackages(t))
+}
+
+func TestProbe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vkgen-probe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, payload := range map[string]string{
+		"users_get_response.json":   `[{"id": 1, "first_name": "Pavel", "is_closed": false}]`,
+		"friends_get_response.json": `{"count": 1, "items": [1]}`,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(payload), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files := generateFiles(t, Options{Probe: dir}, testSchemas{})
+	assertContains(t, files, "probe.gen_test.go", "return new(UsersGetResponse)")
+	srcs := generatedPackage(files)
+	delete(srcs, "stub.go")
+	srcs["probe.gen_test.go"] = files["probe.gen_test.go"]
+	out, err := runGoTest(t, "1.14", srcs)
+	if err == nil {
+		t.Fatalf("extra field is not detected\n%s", out)
+	}
+	if !strings.Contains(string(out), "--- FAIL: TestProbe/users_get_response") ||
+		!strings.Contains(string(out), `schema is stale: json: unknown field "is_closed"`) {
+		t.Errorf("unexpected output\n%s", out)
+	}
+	if strings.Contains(string(out), "FAIL: TestProbe/friends_get_response") {
+		t.Errorf("fixture matching schema fails\n%s", out)
+	}
+}
+
+func TestSummaries(t *testing.T) {
+	files := generateFiles(t, Options{Summaries: true}, testSchemas{})
+	srcs := generatedPackage(files)
+	delete(srcs, "stub.go")
+	srcs["summary_test.go"] = `package generated
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSummary(t *testing.T) {
+	var r FriendsGetResponse
+	if err := json.Unmarshal([]byte(` + "`" + `{"count": 42, "items": [1, 2, 3]}` + "`" + `), &r); err != nil {
+		t.Fatal(err)
+	}
+	if s := r.Summary(); s != "count=42 items=3" {
+		t.Errorf("summary %q", s)
+	}
+}
+`
+	goTest(t, srcs)
+}
+
+func TestAdditionalProperties(t *testing.T) {
+	objects := objectsWith(`
+    "base_counters": {"type": "object", "additionalProperties": {"$ref": "objects.json#/definitions/users_user"}},
+    "base_any": {"type": "object", "additionalProperties": true}`)
+	files := generateFiles(t, Options{}, testSchemas{objects: objects})
+	assertContains(t, files, "objects.gen.go", "type BaseCounters map[string]UsersUser\n")
+	assertContains(t, files, "objects.gen.go", "type BaseAny map[string]interface{}\n")
+	typeCheck(t, pkgName, generatedPackage(files), sdkPackages(t))
+}
+
+func TestSortFields(t *testing.T) {
+	objects := objectsWith(`
+    "base_zed": {"type": "object", "properties": {"zeta": {"type": "integer"}, "alpha": {"type": "string"}, "mid_name": {"type": "string"}}}`)
+	files := generateFiles(t, Options{SortFields: true}, testSchemas{objects: objects})
+	assertContains(t, files, "objects.gen.go", "type BaseZed struct {\n"+
+		"\tAlpha   string `json:\"alpha\"`\n"+
+		"\tMidName string `json:\"mid_name\"`\n"+
+		"\tZeta    int64  `json:\"zeta\"`\n"+
+		"}\n")
+
+	files = generateFiles(t, Options{}, testSchemas{objects: objects})
+	assertContains(t, files, "objects.gen.go", "type BaseZed struct {\n"+
+		"\tZeta    int64  `json:\"zeta\"`\n"+
+		"\tAlpha   string `json:\"alpha\"`\n"+
+		"\tMidName string `json:\"mid_name\"`\n"+
+		"}\n")
+}
+
+func TestUnwrapResponse(t *testing.T) {
+	files := generateFiles(t, Options{Generics: true}, testSchemas{})
+	srcs := generatedPackage(files)
+	// UnwrapResponse converts error of envelope like vksdk
+	srcs["stub.go"] = strings.Replace(strings.Replace(clientStub,
+		"\t\"encoding/json\"\n", "\t\"encoding/json\"\n\n\t\"github.com/SevereCloud/vksdk/object\"\n", 1),
+		"\tResponse json.RawMessage\n", "\tResponse json.RawMessage\n\tError    object.Error `json:\"error\"`\n", 1)
+	srcs["support_test.go"] = `package generated
+
+import "testing"
+
+func TestUnwrapResponse(t *testing.T) {
+	r, err := UnwrapResponse[UsersGetResponse]([]byte(` + "`" + `{"response": [{"id": 1, "first_name": "Pavel"}]}` + "`" + `))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r) != 1 || r[0].ID != 1 || r[0].FirstName != "Pavel" {
+		t.Errorf("response %+v", r)
+	}
+
+	_, err = UnwrapResponse[UsersGetResponse]([]byte(` + "`" + `{"error": {"error_code": 5, "error_msg": "User authorization failed"}}` + "`" + `))
+	if err == nil {
+		t.Error("error of envelope is ignored")
+	}
+}
+`
+	if out, err := runGoTest(t, "1.18", srcs); err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+}
+
+func TestBuilderCSV(t *testing.T) {
+	methods := `{
+  "methods": [
+    {
+      "name": "users.get",
+      "parameters": [
+        {"name": "user_ids", "type": "array", "items": {"type": "integer"}},
+        {"name": "fields", "type": "array", "items": {"type": "string"}}
+      ],
+      "responses": {
+        "response": {"$ref": "responses.json#/definitions/users_get_response"}
+      }
+    }
+  ]
+}`
+	files := generateFiles(t, Options{}, testSchemas{methods: methods})
+	srcs := generatedPackage(files)
+	delete(srcs, "stub.go")
+	srcs["builders_test.go"] = `package generated
+
+import "testing"
+
+func TestBuilderCSV(t *testing.T) {
+	b := NewUsersGetBuilder().UserIDs(1, 2, 3).Fields("photo", "city")
+	if ids := b.Params["user_ids"]; ids != "1,2,3" {
+		t.Errorf("user_ids %#v", ids)
+	}
+	if fields := b.Params["fields"]; fields != "photo,city" {
+		t.Errorf("fields %#v", fields)
+	}
+}
+`
+	goTest(t, srcs)
+}
+
+func TestDeterministicOutput(t *testing.T) {
+	objects := objectsWith(`
+    "users_zed": {"type": "object", "properties": {"id": {"type": "integer"}}}`)
+	first := generateFiles(t, Options{}, testSchemas{objects: objects})
+	second := generateFiles(t, Options{}, testSchemas{objects: objects})
+	if !reflect.DeepEqual(first, second) {
+		t.Error("generated files differ between runs")
+	}
+
+	src := first["objects.gen.go"]
+	user, zed := strings.Index(src, "type UsersUser struct"), strings.Index(src, "type UsersZed struct")
+	if user < 0 || zed < 0 || zed < user {
+		t.Errorf("objects are not sorted by name:\n%s", src)
+	}
+}
+
+func TestBuilderClone(t *testing.T) {
+	files := generateFiles(t, Options{}, testSchemas{})
+	srcs := generatedPackage(files)
+	delete(srcs, "stub.go")
+	srcs["builders_test.go"] = `package generated
+
+import "testing"
+
+func TestBuilderClone(t *testing.T) {
+	b := NewFriendsGetBuilder().UserID(1)
+	clone := b.Clone().UserID(2).Extended(true)
+	if b.Params["user_id"] != int64(1) || len(b.Params) != 1 {
+		t.Errorf("original params changed: %v", b.Params)
+	}
+	if clone.Params["user_id"] != int64(2) || len(clone.Params) != 2 {
+		t.Errorf("clone params %v", clone.Params)
+	}
+}
+`
+	goTest(t, srcs)
+}
+
+func TestMethodResponseReference(t *testing.T) {
+	methods := `{
+  "methods": [
+    {
+      "name": "users.search",
+      "parameters": [],
+      "responses": {
+        "response": {"$ref": "responses.json#/definitions/users_get_response"}
+      }
+    }
+  ]
+}`
+	files := generateFiles(t, Options{}, testSchemas{methods: methods})
+	assertContains(t, files, "methods.gen.go", "func (vk *VK) UsersSearch(params Params) (response UsersGetResponse, err error) {")
+	typeCheck(t, pkgName, generatedPackage(files), sdkPackages(t))
+
+	methods = strings.Replace(methods, "users_get_response", "users_search_response", 1)
+	_, err := runGenerator(t, Options{}, testSchemas{methods: methods})
+	if err == nil || !strings.Contains(err.Error(), "users_search_response: definition not found") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNegativeEnum(t *testing.T) {
+	objects := objectsWith(`
+    "base_sign": {"type": "integer", "enum": [-1, 0, 1]},
+    "base_named_sign": {"type": "integer", "enum": [-1, 0, 1], "enumNames": ["-1", "zero", "one"]}`)
+	files := generateFiles(t, Options{}, testSchemas{objects: objects})
+	assertContains(t, files, "objects.gen.go", "\tBaseSignNeg1 BaseSign = -1\n\tBaseSign0    BaseSign = 0\n\tBaseSign1    BaseSign = 1\n")
+	assertContains(t, files, "objects.gen.go", "\tBaseNamedSignNeg1 BaseNamedSign = -1 // -1\n")
+	typeCheck(t, pkgName, generatedPackage(files), sdkPackages(t))
+}
+
+func TestFieldTags(t *testing.T) {
+	files := generateFiles(t, Options{FieldTags: []string{"db:{snake}", "bson:{camel}"}}, testSchemas{})
+	assertContains(t, files, "objects.gen.go", "`json:\"first_name,omitempty\" db:\"first_name\" bson:\"firstName\"`")
+	srcs := generatedPackage(files)
+	delete(srcs, "stub.go")
+	srcs["tags_test.go"] = `package generated
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFieldTags(t *testing.T) {
+	field, _ := reflect.TypeOf(UsersUser{}).FieldByName("FirstName")
+	for key, want := range map[string]string{
+		"json": "first_name,omitempty",
+		"db":   "first_name",
+		"bson": "firstName",
+	} {
+		if got, ok := field.Tag.Lookup(key); !ok || got != want {
+			t.Errorf("%s tag %q, want %q", key, got, want)
+		}
+	}
+}
+`
+	goTest(t, srcs)
+}
+
+func TestCommentWidth(t *testing.T) {
+	description := strings.TrimSpace(strings.Repeat("Lorem ipsum dolor sit amet, consectetur adipiscing elit. ", 7))
+	objects := objectsWith(`
+    "base_long": {
+      "type": "object",
+      "description": "` + description + `",
+      "properties": {
+        "id": {"type": "integer", "description": "` + description + `"}
+      }
+    }`)
+	files := generateFiles(t, Options{CommentWidth: 100}, testSchemas{objects: objects})
+	src := files["objects.gen.go"]
+	var lines int
+	for _, line := range strings.Split(src, "\n") {
+		comment := strings.TrimLeft(line, "\t")
+		if !strings.HasPrefix(comment, "// ") || !strings.Contains(comment, "Lorem") && !strings.Contains(comment, "elit") {
+			continue
+		}
+		lines++
+		if len(comment) > 100 {
+			t.Errorf("comment is not wrapped: %q", line)
+		}
+	}
+	if lines < 2*len(description)/100 {
+		t.Errorf("description is lost:\n%s", src)
+	}
+	assertContains(t, files, "objects.gen.go", "elit.\ntype BaseLong struct {\n")
+	assertContains(t, files, "objects.gen.go", "elit.\n\tID int64 `json:\"id\"`\n")
+
+	files = generateFiles(t, Options{}, testSchemas{objects: objects})
+	assertContains(t, files, "objects.gen.go", "// "+description+"\ntype BaseLong struct {\n")
+	assertContains(t, files, "objects.gen.go", "\tID int64 `json:\"id\"` // "+description+"\n")
+}
+
+func TestBuilderEnumCSV(t *testing.T) {
+	objects := objectsWith(`
+    "users_fields": {"type": "string", "enum": ["photo", "city"]}`)
+	methods := `{
+  "methods": [
+    {
+      "name": "users.get",
+      "parameters": [
+        {"name": "fields", "type": "array", "items": {"$ref": "objects.json#/definitions/users_fields"}}
+      ],
+      "responses": {
+        "response": {"$ref": "responses.json#/definitions/users_get_response"}
+      }
+    }
+  ]
+}`
+	// builders refer to objects of SDK
+	packages := sdkPackages(t)
+	packages[DefaultSDKImport] = typeCheck(t, DefaultSDKImport, map[string]string{
+		"api.go":     sdkStub,
+		"objects.go": "package api\n\ntype UsersFields string\n",
+	}, nil)
+
+	files := generateFiles(t, Options{}, testSchemas{objects: objects, methods: methods})
+	assertContains(t, files, "builders.gen.go", `func (b *UsersGetBuilder) Fields(v ...api.UsersFields) *UsersGetBuilder {
+	s := make([]string, len(v))
+	for i, e := range v {
+		s[i] = string(e)
+	}
+	b.Params["fields"] = strings.Join(s, ",")
+	return b
+}`)
+	typeCheck(t, pkgName, generatedPackage(files), packages)
+
+	files = generateFiles(t, Options{EnumIntBacked: true}, testSchemas{objects: objects, methods: methods})
+	assertContains(t, files, "builders.gen.go", "\tb.Params[\"fields\"] = v\n")
+	typeCheck(t, pkgName, generatedPackage(files), packages)
+}
+
+func TestEnumValues(t *testing.T) {
+	objects := objectsWith(`
+    "users_fields": {"type": "string", "enum": ["photo", "city", "sex"]}`)
+	responses := strings.Replace(testResponses, `"definitions": {`, `"definitions": {
+    "account_get_status_response": {
+      "type": "object",
+      "properties": {
+        "response": {"type": "integer", "enum": [0, 1, 2, 3]}
+      }
+    },`, 1)
+	files := generateFiles(t, Options{}, testSchemas{objects: objects, responses: responses})
+	srcs := generatedPackage(files)
+	delete(srcs, "stub.go")
+	srcs["enums_test.go"] = `package generated
+
+import "testing"
+
+func TestEnumValues(t *testing.T) {
+	if values := BaseBoolIntValues(); len(values) != 2 || values[0] != BaseBoolIntNo || values[1] != BaseBoolIntYes {
+		t.Errorf("BaseBoolIntValues() = %v", values)
+	}
+	if values := UsersFieldsValues(); len(values) != 3 {
+		t.Errorf("UsersFieldsValues() = %v", values)
+	}
+	if values := AccountGetStatusResponseValues(); len(values) != 4 {
+		t.Errorf("AccountGetStatusResponseValues() = %v", values)
+	}
+}
+`
+	goTest(t, srcs)
+}
+
+func TestIntType(t *testing.T) {
+	objects := objectsWith(`
+    "base_counters": {
+      "type": "object",
+      "properties": {
+        "total": {"type": "integer"},
+        "small": {"type": "integer", "format": "int32"},
+        "large": {"type": "integer", "format": "int64"}
+      }
+    }`)
+	for _, intType := range []string{"", "int", "int32", "int64"} {
+		files := generateFiles(t, Options{IntType: intType}, testSchemas{objects: objects})
+		want := intType
+		if want == "" {
+			want = DefaultIntType
+		}
+		assertContains(t, files, "objects.gen.go", "\tTotal "+want+" ")
+		assertContains(t, files, "objects.gen.go", "\tSmall int32 ")
+		assertContains(t, files, "objects.gen.go", "\tLarge int64 ")
+		assertContains(t, files, "builders.gen.go", "func (b *UsersGetBuilder) Count(v "+want+") *UsersGetBuilder {")
+		typeCheck(t, pkgName, generatedPackage(files), sdkPackages(t))
+	}
+}
+
+func TestSingleReferenceAllOf(t *testing.T) {
+	objects := objectsWith(`
+    "users_user_full": {"allOf": [{"$ref": "objects.json#/definitions/users_user"}]},
+    "groups_group": {
+      "type": "object",
+      "properties": {
+        "admin": {"allOf": [{"$ref": "objects.json#/definitions/users_user"}]}
+      }
+    }`)
+	files := generateFiles(t, Options{}, testSchemas{objects: objects})
+	assertContains(t, files, "objects.gen.go", "type UsersUserFull = UsersUser\n")
+	assertContains(t, files, "objects.gen.go", "\tAdmin UsersUser `json:\"admin\"`\n")
+	typeCheck(t, pkgName, generatedPackage(files), sdkPackages(t))
+}
+
+func TestAllOfDocComment(t *testing.T) {
+	objects := objectsWith(`
+    "users_user_full": {
+      "description": "Full user",
+      "deprecated": "Use users_user.",
+      "allOf": [
+        {"$ref": "objects.json#/definitions/users_user"},
+        {"type": "object", "properties": {"nickname": {"type": "string"}}}
+      ]
+    }`)
+	files := generateFiles(t, Options{}, testSchemas{objects: objects})
+	assertContains(t, files, "objects.gen.go", "// Full user\n//\n// Deprecated: Use users_user.\ntype UsersUserFull struct {\n")
+	typeCheck(t, pkgName, generatedPackage(files), sdkPackages(t))
+}
+
+func TestURLValues(t *testing.T) {
+	files := generateFiles(t, Options{URLValues: true}, testSchemas{})
+	srcs := generatedPackage(files)
+	delete(srcs, "stub.go")
+	srcs["values_test.go"] = `package generated
+
+import "testing"
+
+func TestToValues(t *testing.T) {
+	values, err := UsersGet{UserIDs: []string{"durov", "1"}, Count: 5}.ToValues()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := values.Encode(); got != "count=5&user_ids=durov%2C1" {
+		t.Errorf("users.get values %s", got)
+	}
+
+	values, err = FriendsGet{UserID: 1, Extended: true}.ToValues()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := values.Encode(); got != "extended=1&user_id=1" {
+		t.Errorf("friends.get values %s", got)
+	}
+}
+`
+	goTest(t, srcs)
+}
+
+func TestRecursiveObjects(t *testing.T) {
+	objects := objectsWith(`
+    "base_tree": {
+      "type": "object",
+      "properties": {
+        "parent": {"$ref": "objects.json#/definitions/base_tree"},
+        "children": {"type": "array", "items": {"$ref": "objects.json#/definitions/base_tree"}},
+        "levels": {"type": "array", "items": {"type": "array", "items": {"$ref": "objects.json#/definitions/base_tree"}}}
+      },
+      "required": ["parent", "children", "levels"]
+    },
+    "wall_post": {
+      "type": "object",
+      "properties": {
+        "copy": {"$ref": "objects.json#/definitions/wall_copy"}
+      },
+      "required": ["copy"]
+    },
+    "wall_copy": {
+      "type": "object",
+      "properties": {
+        "post": {"$ref": "objects.json#/definitions/wall_post"},
+        "owner": {"$ref": "objects.json#/definitions/users_user"}
+      },
+      "required": ["post", "owner"]
+    }`)
+	files := generateFiles(t, Options{}, testSchemas{objects: objects})
+	assertContains(t, files, "objects.gen.go", "type BaseTree struct {\n"+
+		"\tParent   *BaseTree    `json:\"parent\"`\n"+
+		"\tChildren []BaseTree   `json:\"children\"`\n"+
+		"\tLevels   [][]BaseTree `json:\"levels\"`\n"+
+		"}\n")
+	assertContains(t, files, "objects.gen.go", "\tCopy *WallCopy `json:\"copy\"`\n")
+	assertContains(t, files, "objects.gen.go", "\tPost  *WallPost `json:\"post\"`\n")
+	assertContains(t, files, "objects.gen.go", "\tOwner UsersUser `json:\"owner\"`\n")
+	typeCheck(t, pkgName, generatedPackage(files), sdkPackages(t))
+}
+
+func TestPatternProperties(t *testing.T) {
+	objects := objectsWith(`
+    "messages_delete_result": {"type": "object", "patternProperties": {"^[0-9]+$": {"type": "integer"}}},
+    "messages_mixed": {
+      "type": "object",
+      "patternProperties": {
+        "^[0-9]+$": {"type": "integer"},
+        "^[a-z]+$": {"type": "string"}
+      }
+    }`)
+	files := generateFiles(t, Options{}, testSchemas{objects: objects})
+	assertContains(t, files, "objects.gen.go", "type MessagesDeleteResult map[string]int64\n")
+	assertContains(t, files, "objects.gen.go", "type MessagesMixed map[string]json.RawMessage\n")
+	typeCheck(t, pkgName, generatedPackage(files), sdkPackages(t))
+}
+
+func TestDeepCopy(t *testing.T) {
+	objects := objectsWith(`
+    "base_counters": {"type": "object", "additionalProperties": {"type": "integer"}},
+    "groups_group": {
+      "type": "object",
+      "properties": {
+        "admin": {"$ref": "objects.json#/definitions/users_user"},
+        "members": {"type": "array", "items": {"$ref": "objects.json#/definitions/users_user"}},
+        "tags": {"type": "array", "items": {"type": "string"}},
+        "counters": {"$ref": "objects.json#/definitions/base_counters"}
+      },
+      "required": ["admin"]
+    }`)
+	files := generateFiles(t, Options{DeepCopy: true}, testSchemas{objects: objects})
+	srcs := generatedPackage(files)
+	delete(srcs, "stub.go")
+	srcs["deepcopy_test.go"] = `package generated
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDeepCopy(t *testing.T) {
+	group := &GroupsGroup{
+		Admin:    UsersUser{ID: 1, FirstName: "Pavel"},
+		Members:  []UsersUser{{ID: 2}},
+		Tags:     []string{"news"},
+		Counters: &BaseCounters{"posts": 10},
+	}
+	clone := group.DeepCopy()
+	if !reflect.DeepEqual(group, clone) {
+		t.Fatalf("copy %+v differs from %+v", clone, group)
+	}
+
+	clone.Admin.FirstName = "Nikolai"
+	clone.Members[0].ID = 3
+	clone.Tags[0] = "music"
+	(*clone.Counters)["posts"] = 11
+	if group.Admin.FirstName != "Pavel" || group.Members[0].ID != 2 || group.Tags[0] != "news" || (*group.Counters)["posts"] != 10 {
+		t.Errorf("original changed by mutation of copy: %+v", group)
+	}
+}
+`
+	goTest(t, srcs)
+}
+
+func TestOptionalMode(t *testing.T) {
+	responses := strings.Replace(testResponses, `"definitions": {`, `"definitions": {
+    "account_get_info_response": {
+      "type": "object",
+      "properties": {
+        "response": {
+          "type": "object",
+          "properties": {
+            "owner": {"$ref": "objects.json#/definitions/users_user"},
+            "admin": {"$ref": "objects.json#/definitions/users_user"},
+            "country": {"type": "string"}
+          },
+          "required": ["owner"]
+        }
+      }
+    },`, 1)
+	for mode, want := range map[string]string{
+		"": "type AccountGetInfoResponse struct {\n" +
+			"\tOwner   UsersUser  `json:\"owner\"`\n" +
+			"\tAdmin   *UsersUser `json:\"admin,omitempty\"`\n" +
+			"\tCountry string     `json:\"country,omitempty\"`\n" +
+			"}\n",
+		OptionalPointer: "type AccountGetInfoResponse struct {\n" +
+			"\tOwner   UsersUser  `json:\"owner\"`\n" +
+			"\tAdmin   *UsersUser `json:\"admin,omitempty\"`\n" +
+			"\tCountry string     `json:\"country,omitempty\"`\n" +
+			"}\n",
+		OptionalOmitempty: "type AccountGetInfoResponse struct {\n" +
+			"\tOwner   UsersUser `json:\"owner\"`\n" +
+			"\tAdmin   UsersUser `json:\"admin,omitempty\"`\n" +
+			"\tCountry string    `json:\"country,omitempty\"`\n" +
+			"}\n",
+	} {
+		files := generateFiles(t, Options{OptionalMode: mode}, testSchemas{responses: responses})
+		assertContains(t, files, "responses.gen.go", want)
+		typeCheck(t, pkgName, generatedPackage(files), sdkPackages(t))
+	}
+}
+
+func TestBuilderNestedArray(t *testing.T) {
+	methods := `{
+  "methods": [
+    {
+      "name": "users.get",
+      "parameters": [
+        {"name": "matrix", "type": "array", "items": {"type": "array", "items": {"type": "integer"}}},
+        {"name": "user_ids", "type": "array", "items": {"type": "integer"}}
+      ],
+      "responses": {
+        "response": {"$ref": "responses.json#/definitions/users_get_response"}
+      }
+    }
+  ]
+}`
+	files := generateFiles(t, Options{Examples: true}, testSchemas{methods: methods})
+	assertContains(t, files, "builders.gen.go", "func (b *UsersGetBuilder) UserIDs(v ...int64) *UsersGetBuilder {")
+	assertContains(t, files, "builders.gen.go", "func (b *UsersGetBuilder) Matrix(v [][]int64) *UsersGetBuilder {")
+	assertContains(t, files, "builders.gen.go", "//\tparams := NewUsersGetBuilder().UserIDs(1).Params\n")
+	typeCheck(t, pkgName, generatedPackage(files), sdkPackages(t))
+}
+
+func TestShortEnumNames(t *testing.T) {
+	objects := objectsWith(`
+    "base_state": {"type": "string", "enum": ["alpha", "beta", "c"], "enumNames": ["first", "second"]}`)
+	files := generateFiles(t, Options{}, testSchemas{objects: objects})
+	assertContains(t, files, "objects.gen.go", "\tBaseStateFirst  BaseState = \"alpha\" // first\n"+
+		"\tBaseStateSecond BaseState = \"beta\"  // second\n"+
+		"\tBaseStateC      BaseState = \"c\"\n")
+	typeCheck(t, pkgName, generatedPackage(files), sdkPackages(t))
+}
+
+func TestPrefix(t *testing.T) {
+	files := generateFiles(t, Options{Prefix: "VK"}, testSchemas{})
+	assertContains(t, files, "objects.gen.go", "type VKUsersUser struct {")
+	assertContains(t, files, "objects.gen.go", "\tVKBaseBoolIntYes VKBaseBoolInt = 1 // yes\n")
+	assertContains(t, files, "objects.gen.go", "`json:\"first_name,omitempty\"`")
+	assertContains(t, files, "responses.gen.go", "type VKUsersGetResponse []VKUsersUser\n")
+	assertContains(t, files, "responses.gen.go", "\tItems []VKUsersUser `json:\"items\"`\n")
+	assertContains(t, files, "methods.gen.go", "func (vk *VK) UsersGet(params Params) (response VKUsersGetResponse, err error) {\n"+
+		"\terr = vk.RequestUnmarshal(\"users.get\", params, &response)\n")
+	assertContains(t, files, "builders.gen.go", "type VKUsersGetBuilder struct {")
+	assertContains(t, files, "builders.gen.go", "func (b *VKUsersGetBuilder) Execute(vk *VK) (response VKUsersGetResponse, err error) {")
+	assertContains(t, files, "requests.gen.go", "type VKUsersGet struct {")
+	typeCheck(t, pkgName, generatedPackage(files), sdkPackages(t))
+}
+
+func TestEmbedRule(t *testing.T) {
+	objects := objectsWith(`
+    "base_object": {
+      "type": "object",
+      "properties": {"id": {"type": "integer"}, "title": {"type": "string"}},
+      "required": ["id", "title"]
+    },
+    "database_city": {
+      "type": "object",
+      "properties": {"id": {"type": "integer"}, "title": {"type": "string"}, "area": {"type": "string"}},
+      "required": ["id", "title", "area"]
+    }`)
+	rules := Rules{"objects.gen.go": {"DatabaseCity": {"BaseObject": ",embed"}}}
+	files := generateFiles(t, Options{Rules: rules}, testSchemas{objects: objects})
+	assertContains(t, files, "objects.gen.go", "type DatabaseCity struct {\n\tBaseObject\n\tArea string `json:\"area\"`\n}\n")
+	typeCheck(t, pkgName, generatedPackage(files), sdkPackages(t))
+}
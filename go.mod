@@ -6,4 +6,5 @@ require (
 	github.com/SevereCloud/vksdk v1.10.0
 	github.com/tidwall/gjson v1.6.0
 	github.com/urfave/cli/v2 v2.2.0
+	gopkg.in/yaml.v2 v2.4.0
 )
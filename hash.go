@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/cqln/vkgen/schema"
+)
+
+// schemaHashFile holds hash of inputs of the run generated it.
+const schemaHashFile = pkgName + "/schema_hash.gen.go"
+
+var schemaHashConst = regexp.MustCompile(`const SchemaHash = "([0-9a-f]{64})"`)
+
+// inputHash returns SHA-256 of schemas, test fixtures and options affecting
+// generated code. Every input is prefixed by its name and length, so moving
+// bytes between inputs changes the hash.
+func (g Generator) inputHash() (string, error) {
+	opts := g.options
+	opts.Source = nil
+	opts.DryRun = false
+	opts.IfChanged = false
+	optsJSON, err := json.Marshal(opts)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	write := func(name string, data []byte) {
+		h.Write([]byte(name + "\n" + strconv.Itoa(len(data)) + "\n"))
+		h.Write(data)
+	}
+	write("options", optsJSON)
+
+	schemaTypes := []schema.SchemaType{schema.ObjectsSchema, schema.MethodsSchema, schema.ResponsesSchema}
+	if g.schemaPaths[schema.ErrorsSchema] != "" {
+		schemaTypes = append(schemaTypes, schema.ErrorsSchema)
+	}
+	for _, schemaType := range schemaTypes {
+		data, err := g.readSchema(schemaType)
+		if err != nil {
+			return "", err
+		}
+		write(string(schemaType), data)
+	}
+
+	fixtures, err := g.testFixtures()
+	if err != nil {
+		return "", err
+	}
+	for _, path := range fixtures {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		write("fixture "+filepath.Base(path), data)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// generatedHash returns hash embedded in schema hash file, empty if there
+// is none.
+func generatedHash() string {
+	data, err := ioutil.ReadFile(schemaHashFile)
+	if err != nil {
+		return ""
+	}
+	m := schemaHashConst.FindSubmatch(data)
+	if m == nil {
+		return ""
+	}
+	return string(m[1])
+}
+
+// generateSchemaHash writes hash of inputs of the run.
+func (g Generator) generateSchemaHash(hash string) error {
+	b := bytes.NewBuffer(nil)
+	b.WriteString(genPrefix + "\n\n")
+	b.WriteString("// Schema hash " + hash + ".\n\n")
+	b.WriteString("package " + pkgName + "\n\n")
+	b.WriteString("// SchemaHash is SHA-256 of schemas and options the package is generated\n")
+	b.WriteString("// from, vkgen -if-changed skips generation if it is up to date.\n")
+	b.WriteString("const SchemaHash = " + strconv.Quote(hash) + "\n")
+	return g.writeSource(schemaHashFile, b)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// InterfaceRule describes interface implemented by a set of objects.
+type InterfaceRule struct {
+	// Method signature, e.g. "MediaOwnerID() int64".
+	Method string `json:"method"`
+	// Field returned by the method. Methods without field must not
+	// have results and get an empty body.
+	Field string `json:"field"`
+	// Objects are generated type names implementing the interface.
+	Objects []string `json:"objects"`
+}
+
+// Interfaces maps interface name to its rule.
+type Interfaces map[string]InterfaceRule
+
+// LoadInterfaces reads interfaces from JSON file.
+func LoadInterfaces(path string) (Interfaces, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var ifaces Interfaces
+	if err := json.Unmarshal(data, &ifaces); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if err := ifaces.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return ifaces, nil
+}
+
+func (ifaces Interfaces) validate() error {
+	for name, rule := range ifaces {
+		if !token.IsIdentifier(name) {
+			return fmt.Errorf("invalid interface name %q", name)
+		}
+
+		results, err := methodResults(rule.Method)
+		if err != nil {
+			return fmt.Errorf("%s: invalid method %q", name, rule.Method)
+		}
+		if rule.Field == "" && results > 0 {
+			return fmt.Errorf("%s: method with results requires field", name)
+		}
+		if rule.Field != "" && !token.IsIdentifier(rule.Field) {
+			return fmt.Errorf("%s: invalid field name %q", name, rule.Field)
+		}
+
+		for _, obj := range rule.Objects {
+			if !token.IsIdentifier(obj) {
+				return fmt.Errorf("%s: invalid object name %q", name, obj)
+			}
+		}
+	}
+	return nil
+}
+
+// methodResults returns number of results of method signature.
+func methodResults(method string) (int, error) {
+	expr, err := parser.ParseExpr("interface{" + method + "}")
+	if err != nil {
+		return 0, err
+	}
+
+	methods := expr.(*ast.InterfaceType).Methods.List
+	if len(methods) != 1 || len(methods[0].Names) != 1 {
+		return 0, fmt.Errorf("expected single method")
+	}
+	return methods[0].Type.(*ast.FuncType).Results.NumFields(), nil
+}
+
+// declarations generates interface types.
+func (ifaces Interfaces) declarations() string {
+	var names []string
+	for name := range ifaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		sb.WriteString("type " + name + " interface {\n")
+		sb.WriteString("\t" + ifaces[name].Method + "\n")
+		sb.WriteString("}\n\n")
+	}
+	return sb.String()
+}
+
+// methods generates methods of interfaces implemented by object gname with
+// receiver recv.
+func (ifaces Interfaces) methods(recv, gname string) string {
+	var names []string
+	for name, rule := range ifaces {
+		for _, obj := range rule.Objects {
+			if obj == gname {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		rule := ifaces[name]
+		sb.WriteString("\n// " + gname + " implements " + name + ".\n")
+		sb.WriteString("func (" + recv + ") " + rule.Method + " {")
+		if rule.Field != "" {
+			sb.WriteString("\n\treturn o." + rule.Field + "\n")
+		}
+		sb.WriteString("}\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cqln/vkgen/schema"
+)
+
+// linter collects issues of schemas found without generating code.
+type linter struct {
+	g      Generator
+	issues []string
+}
+
+// Lint parses objects, methods and responses schemas and reports
+// unresolved references, enums without values or with mismatched names,
+// properties without type and allOf and oneOf expressions which generator
+// falls back on. Issues are printed, error reports their number.
+func (g Generator) Lint() error {
+	l := &linter{g: g}
+	// expressions are only inspected, inline structs must not be
+	// registered
+	l.g.inline = nil
+
+	responsesSchema, err := g.readSchema(schema.ResponsesSchema)
+	if err != nil {
+		return fmt.Errorf("responses: %w", err)
+	}
+	g.parser.SetResponses(responsesSchema)
+
+	objectsSchema, err := g.readSchema(schema.ObjectsSchema)
+	if err != nil {
+		return err
+	}
+	objects, err := g.parser.ParseObjects(objectsSchema)
+	if err != nil {
+		return fmt.Errorf("%s: %w", schema.ObjectsSchema, err)
+	}
+	for _, obj := range objects {
+		l.walk(string(schema.ObjectsSchema)+": "+obj.Name, obj.Name, obj.Expr)
+	}
+
+	responses, err := g.parseResponses(responsesSchema)
+	if err != nil {
+		return fmt.Errorf("%s: %w", schema.ResponsesSchema, err)
+	}
+	for _, resp := range responses {
+		l.walk(string(schema.ResponsesSchema)+": "+resp.Name, resp.Name, resp.Expr.ObjectExpr)
+	}
+
+	methodsSchema, err := g.readSchema(schema.MethodsSchema)
+	if err != nil {
+		return err
+	}
+	methods, err := g.parseMethods(methodsSchema)
+	if err != nil {
+		return fmt.Errorf("%s: %w", schema.MethodsSchema, err)
+	}
+	for _, method := range methods {
+		path := string(schema.MethodsSchema) + ": " + method.Name
+		for _, param := range method.Parameters {
+			l.walk(path+".parameters."+param.Name, method.Name, param.ObjectExpr)
+		}
+		for _, response := range method.Responses {
+			l.walk(path+".responses."+response.Name, method.Name, response.Expr)
+		}
+	}
+
+	if len(l.issues) == 0 {
+		fmt.Println("no issues found")
+		return nil
+	}
+	for _, issue := range l.issues {
+		fmt.Println(issue)
+	}
+	return fmt.Errorf("%d schema issues found", len(l.issues))
+}
+
+func (l *linter) report(path, format string, args ...interface{}) {
+	l.issues = append(l.issues, path+": "+fmt.Sprintf(format, args...))
+}
+
+// walk checks expression and its nested expressions. References are only
+// resolved, definitions are checked by their own paths.
+func (l *linter) walk(path, name string, expr schema.ObjectExpr) {
+	switch {
+	case expr.IsReference:
+		if _, err := expr.Ref(); err != nil {
+			l.report(path, "unresolved reference: %v", err)
+		}
+		return
+	case expr.IsEnum && len(expr.Enum) == 0:
+		l.report(path, "enum without values")
+	case expr.IsEnum && len(expr.EnumNames) > 0 && len(expr.EnumNames) != len(expr.Enum):
+		l.report(path, "%d enum names for %d values", len(expr.EnumNames), len(expr.Enum))
+	case expr.IsAllOf:
+		l.checkAllOf(path, expr)
+		for i, item := range expr.AllOf {
+			l.walk(fmt.Sprintf("%s.allOf[%d]", path, i), name, item)
+		}
+		return
+	case expr.IsOneOf:
+		if _, ok, err := l.g.discriminatedOneOf(l.g.goify(name), expr); err != nil {
+			l.report(path, "oneOf can't be generated: %v", err)
+		} else if !ok {
+			l.report(path, "oneOf is merged into struct of optional branches")
+		}
+		for i, item := range expr.OneOf {
+			l.walk(fmt.Sprintf("%s.oneOf[%d]", path, i), name, item)
+		}
+	case expr.Type == "" && len(expr.Properties) == 0 && expr.AdditionalProperties == nil && len(expr.PatternProperties) == 0:
+		l.report(path, "no type, generated as %s", strings.ReplaceAll(l.g.emptyObjectType(), "\n", ""))
+	}
+
+	for _, prop := range expr.Properties {
+		l.walk(path+".properties."+prop.Name, name, prop.Expr)
+	}
+	if expr.ArrayOf != nil {
+		l.walk(path+".items", name, *expr.ArrayOf)
+	}
+	if expr.AdditionalProperties != nil && !isEmptyObjectExpr(*expr.AdditionalProperties) {
+		l.walk(path+".additionalProperties", name, *expr.AdditionalProperties)
+	}
+	for _, pattern := range expr.PatternProperties {
+		l.walk(path+".patternProperties."+pattern.Name, name, pattern.Expr)
+	}
+}
+
+// checkAllOf reports allOf properties which have different types in
+// branches and are generated as json.RawMessage.
+func (l *linter) checkAllOf(path string, expr schema.ObjectExpr) {
+	allofFields, err := l.g.allofExtractFields(expr)
+	if err != nil {
+		l.report(path, "allOf can't be merged: %v", err)
+		return
+	}
+
+	var conflicts []string
+	for propName, fields := range allofFields {
+		equal, err := equalExprs(fields)
+		if err != nil {
+			l.report(path, "allOf can't be merged: %v", err)
+			return
+		}
+		if !equal {
+			conflicts = append(conflicts, propName)
+		}
+	}
+	sort.Strings(conflicts)
+	if len(conflicts) > 0 {
+		l.report(path, "allOf properties with different types are generated as json.RawMessage: %s", strings.Join(conflicts, ", "))
+	}
+}
@@ -1,31 +1,206 @@
 package main
 
 import (
-	"io/ioutil"
+	"fmt"
 	"log"
 	"os"
+	"regexp"
+	"strings"
 
+	"github.com/cqln/vkgen/schema"
 	"github.com/urfave/cli/v2"
 )
 
 func generateSchemaCmd(c *cli.Context) error {
-	objschema, err := ioutil.ReadFile("objects.json")
+	configPath := c.String("config")
+	if configPath == "" {
+		configPath = findConfig()
+	}
+	if configPath != "" {
+		config, err := LoadConfig(configPath)
+		if err != nil {
+			return err
+		}
+		if err := applyConfig(c, config); err != nil {
+			return fmt.Errorf("%s: %w", configPath, err)
+		}
+	}
+
+	if !IsValidEmptyObjectPolicy(c.String("empty-objects")) {
+		return fmt.Errorf("unknown empty objects policy: %s", c.String("empty-objects"))
+	}
+
+	if !IsValidUnknownType(c.String("unknown-type")) {
+		return fmt.Errorf("unknown type of untyped objects: %s", c.String("unknown-type"))
+	}
+
+	if !IsValidIntegerType(c.String("int-type")) {
+		return fmt.Errorf("unknown integer type: %s", c.String("int-type"))
+	}
+
+	if !IsValidReceiver(c.String("receiver")) {
+		return fmt.Errorf("unknown receiver kind: %s", c.String("receiver"))
+	}
+
+	if !IsValidOptionalMode(c.String("optional-mode")) {
+		return fmt.Errorf("unknown optional fields mode: %s", c.String("optional-mode"))
+	}
+
+	if !IsValidPrefix(c.String("prefix")) {
+		return fmt.Errorf("prefix of type names is not exported identifier: %s", c.String("prefix"))
+	}
+
+	for _, template := range c.StringSlice("fieldtags") {
+		if err := ValidateFieldTag(template); err != nil {
+			return err
+		}
+	}
+
+	rules := kekRules
+	if path := c.String("rules"); path != "" {
+		fileRules, err := LoadRules(path)
+		if err != nil {
+			return err
+		}
+		rules = rules.Merge(fileRules)
+	}
+
+	var interfaces Interfaces
+	if path := c.String("interfaces"); path != "" {
+		var err error
+		interfaces, err = LoadInterfaces(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	var bitmasks Bitmasks
+	if path := c.String("bitmasks"); path != "" {
+		var err error
+		bitmasks, err = LoadBitmasks(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	source := NewSchemaSource(c.Duration("timeout"))
+	defer source.Close()
+
+	schemaPaths := map[schema.SchemaType]string{
+		schema.ObjectsSchema:   c.String("objects"),
+		schema.MethodsSchema:   c.String("methods"),
+		schema.ResponsesSchema: c.String("responses"),
+		schema.ErrorsSchema:    c.String("errors"),
+	}
+
+	objschema, err := source.Read(schemaPaths[schema.ObjectsSchema])
 	if err != nil {
 		return err
 	}
-	return NewGenerator(
-		c.Bool("nofmt"),
-		c.Bool("nogoify"),
-		c.Bool("debug"),
-		objschema,
-	).Generate()
+	g := NewGenerator(Options{
+		Nofmt:           c.Bool("nofmt"),
+		Nogoify:         c.Bool("nogoify"),
+		Debug:           c.Bool("debug"),
+		StrictEnums:     c.Bool("strict-enums"),
+		ExtendedMerge:   c.Bool("extended-merge"),
+		DefaultTags:     c.Bool("default-tags"),
+		DedupInline:     c.Bool("dedup-inline"),
+		NamedInline:     c.Bool("named-inline"),
+		PreserveUnknown: c.Bool("preserve-unknown"),
+		Context:         c.Bool("context"),
+		EnumIntBacked:   c.Bool("enum-int-backed"),
+		PerCallToken:    c.Bool("per-call-token"),
+		LenientParams:   c.Bool("lenient-params"),
+		Summaries:       c.Bool("summaries"),
+		SortFields:      c.Bool("sort-fields"),
+		Generics:        c.Bool("generics"),
+		Time:            c.Bool("time"),
+		OneOfInterfaces: c.Bool("oneof-interfaces"),
+		JSONNumber:      c.Bool("json-number"),
+		Getters:         c.Bool("getters"),
+		Constructors:    c.Bool("constructors"),
+		WrapErrors:      c.Bool("wrap-errors"),
+		URLValues:       c.Bool("url-values"),
+		RequestDefaults: c.Bool("request-defaults"),
+		Stamp:           c.Bool("stamp"),
+		Examples:        c.Bool("examples"),
+		DeepCopy:        c.Bool("deepcopy"),
+		DryRun:          c.Bool("dry-run"),
+		IfChanged:       c.Bool("if-changed"),
+		NoComments:      c.Bool("no-comments"),
+		EmptyObjects:    c.String("empty-objects"),
+		UnknownType:     c.String("unknown-type"),
+		IntType:         c.String("int-type"),
+		Receiver:        c.String("receiver"),
+		OptionalMode:    c.String("optional-mode"),
+		Probe:           c.String("probe"),
+		Tests:           c.String("tests"),
+		SDKImport:       c.String("sdk-import"),
+		Prefix:          c.String("prefix"),
+		Command:         command(os.Args),
+		Split:           c.String("split"),
+		CommentWidth:    c.Int("comment-width"),
+		Comparable:      c.StringSlice("comparable"),
+		FieldTags:       c.StringSlice("fieldtags"),
+		Rules:           rules,
+		Interfaces:      interfaces,
+		Bitmasks:        bitmasks,
+		Source:          source,
+		SchemaPaths:     schemaPaths,
+	}, objschema)
+	if c.Bool("lint") {
+		return g.Lint()
+	}
+	return g.Generate()
 }
 
+// command returns shell command reproducing the run.
+func command(args []string) string {
+	quoted := []string{"vkgen"}
+	for _, arg := range args[1:] {
+		if !shellSafe.MatchString(arg) {
+			arg = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+		quoted = append(quoted, arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+var shellSafe = regexp.MustCompile(`^[\w@%+=:,./-]+$`)
+
 func main() {
 	app := &cli.App{
 		Name:  "vkgen",
 		Usage: "generates Golang sources from VK Schema",
 		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "config",
+				Usage: "JSON or YAML file with flag values overridden by command line, vkgen.json or vkgen.yaml of working directory if unset",
+			},
+			&cli.StringFlag{
+				Name:  "objects",
+				Usage: "objects schema file path or URL",
+				Value: string(schema.ObjectsSchema),
+			},
+			&cli.StringFlag{
+				Name:  "methods",
+				Usage: "methods schema file path or URL",
+				Value: string(schema.MethodsSchema),
+			},
+			&cli.StringFlag{
+				Name:  "responses",
+				Usage: "responses schema file path or URL",
+				Value: string(schema.ResponsesSchema),
+			},
+			&cli.StringFlag{
+				Name:  "errors",
+				Usage: "errors schema file path or URL, error codes are not generated if unset",
+			},
+			&cli.DurationFlag{
+				Name:  "timeout",
+				Usage: "schema download timeout",
+				Value: DefaultSchemaTimeout,
+			},
 			&cli.BoolFlag{
 				Name:  "nofmt",
 				Usage: "disable code formatting",
@@ -38,6 +213,189 @@ func main() {
 				Name:  "debug",
 				Usage: "print debug information",
 			},
+			&cli.BoolFlag{
+				Name:  "strict-enums",
+				Usage: "reject unknown values of string enums in JSON",
+			},
+			&cli.BoolFlag{
+				Name:  "extended-merge",
+				Usage: "generate Append method for extended responses",
+			},
+			&cli.BoolFlag{
+				Name:  "default-tags",
+				Usage: "add default struct tags with schema default values",
+			},
+			&cli.BoolFlag{
+				Name:  "dedup-inline",
+				Usage: "hoist identical anonymous structs into shared named types",
+			},
+			&cli.BoolFlag{
+				Name:  "named-inline",
+				Usage: "name inline object types after parent type and property",
+			},
+			&cli.BoolFlag{
+				Name:  "preserve-unknown",
+				Usage: "keep fields missing in schema when unmarshaling and marshaling structs",
+			},
+			&cli.BoolFlag{
+				Name:  "context",
+				Usage: "add context.Context parameter to generated methods",
+			},
+			&cli.BoolFlag{
+				Name:  "enum-int-backed",
+				Usage: "represent string enums as ints mapped to schema values",
+			},
+			&cli.BoolFlag{
+				Name:  "per-call-token",
+				Usage: "add access token field to request types and builders",
+			},
+			&cli.BoolFlag{
+				Name:  "lenient-params",
+				Usage: "do not check required parameters of request types",
+			},
+			&cli.BoolFlag{
+				Name:  "summaries",
+				Usage: "generate Summary method of response types for logging",
+			},
+			&cli.BoolFlag{
+				Name:  "sort-fields",
+				Usage: "sort struct fields alphabetically by Go name instead of schema order",
+			},
+			&cli.BoolFlag{
+				Name:  "generics",
+				Usage: "generate helpers using generics, requires Go 1.18",
+			},
+			&cli.BoolFlag{
+				Name:  "time",
+				Usage: "represent date-time strings of objects and responses as time.Time",
+			},
+			&cli.BoolFlag{
+				Name:  "oneof-interfaces",
+				Usage: "generate oneOf types with discriminator as interfaces dispatched on unmarshaling",
+			},
+			&cli.BoolFlag{
+				Name:  "json-number",
+				Usage: "represent integers and numbers of objects and responses as json.Number",
+			},
+			&cli.BoolFlag{
+				Name:  "getters",
+				Usage: "generate getters of pointer fields returning zero value if unset",
+			},
+			&cli.BoolFlag{
+				Name:  "constructors",
+				Usage: "generate constructors of objects and responses with functional options setting fields",
+			},
+			&cli.BoolFlag{
+				Name:  "wrap-errors",
+				Usage: "wrap errors of generated methods into VKError with method name and error code",
+			},
+			&cli.BoolFlag{
+				Name:  "url-values",
+				Usage: "generate ToValues methods of requests returning parameters as url.Values",
+			},
+			&cli.BoolFlag{
+				Name:  "request-defaults",
+				Usage: "generate constructors of request types with parameters set to schema defaults",
+			},
+			&cli.BoolFlag{
+				Name:  "stamp",
+				Usage: "add schema version and go:generate directive of the run to generated files",
+			},
+			&cli.BoolFlag{
+				Name:  "deepcopy",
+				Usage: "generate DeepCopy methods of object types",
+			},
+			&cli.BoolFlag{
+				Name:  "examples",
+				Usage: "add examples of setter chains to doc comments of builders",
+			},
+			&cli.BoolFlag{
+				Name:  "lint",
+				Usage: "report schema issues instead of generating code",
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "report generated files which differ from files on disk without writing them, fail if any",
+			},
+			&cli.BoolFlag{
+				Name:  "if-changed",
+				Usage: "skip generation if hash of schemas and options matches hash of generated package",
+			},
+			&cli.BoolFlag{
+				Name:  "no-comments",
+				Usage: "omit schema descriptions from generated code",
+			},
+			&cli.IntFlag{
+				Name:  "comment-width",
+				Usage: "wrap schema descriptions at the column, 0 disables wrapping",
+				Value: 100,
+			},
+			&cli.StringFlag{
+				Name:  "empty-objects",
+				Usage: "type for objects without properties: empty-struct, raw-message or any",
+				Value: EmptyObjectStruct,
+			},
+			&cli.StringFlag{
+				Name:  "unknown-type",
+				Usage: "type of inline objects without properties and untyped values: any, raw-message or map",
+				Value: UnknownAny,
+			},
+			&cli.StringFlag{
+				Name:  "int-type",
+				Usage: "Go type of integers: int, int32 or int64, integer format of schema takes precedence",
+				Value: DefaultIntType,
+			},
+			&cli.StringFlag{
+				Name:  "receiver",
+				Usage: "receiver kind of methods of objects and responses: pointer or value",
+				Value: ReceiverPointer,
+			},
+			&cli.StringFlag{
+				Name:  "optional-mode",
+				Usage: "optional fields of objects and responses: pointer or omitempty to keep values with omitempty tag",
+				Value: OptionalPointer,
+			},
+			&cli.StringFlag{
+				Name:  "probe",
+				Usage: "directory with live API responses to generate schema drift test for",
+			},
+			&cli.StringFlag{
+				Name:  "tests",
+				Usage: "directory with method response fixtures, e.g. examples/users.get.json, to generate round-trip tests of response types from",
+			},
+			&cli.StringFlag{
+				Name:  "sdk-import",
+				Usage: "import path of vksdk api package used by generated code",
+				Value: DefaultSDKImport,
+			},
+			&cli.StringFlag{
+				Name:  "prefix",
+				Usage: "prefix of names of generated types, e.g. VK",
+			},
+			&cli.StringFlag{
+				Name:  "split",
+				Usage: "import path of generated package, methods of every namespace are generated in its subpackage importing it",
+			},
+			&cli.StringFlag{
+				Name:  "rules",
+				Usage: "JSON or YAML file with field type overrides: file -> struct -> field -> type",
+			},
+			&cli.StringFlag{
+				Name:  "interfaces",
+				Usage: "JSON file with interfaces implemented by generated objects",
+			},
+			&cli.StringFlag{
+				Name:  "bitmasks",
+				Usage: "JSON file with flag-set types of bitmask fields",
+			},
+			&cli.StringSliceFlag{
+				Name:  "fieldtags",
+				Usage: "additional struct tags of object and response fields, e.g. db:{snake}; {name}, {snake} and {camel} are replaced with transforms of JSON name",
+			},
+			&cli.StringSliceFlag{
+				Name:  "comparable",
+				Usage: "generated types which must stay comparable to be used as map keys",
+			},
 		},
 		HideHelpCommand: true,
 		Action:          generateSchemaCmd,
@@ -0,0 +1,528 @@
+package patcher
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Patcher modifies declarations of generated Go source.
+type Patcher struct {
+	fset *token.FileSet
+	file *ast.File
+}
+
+// StructOp modifies struct type declaration.
+type StructOp func(st *ast.StructType) error
+
+func NewPatcher(src []byte) (*Patcher, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Patcher{
+		fset: fset,
+		file: file,
+	}, nil
+}
+
+// PatchStruct applies ops to struct type declaration with name.
+func (p *Patcher) PatchStruct(name string, ops ...StructOp) error {
+	st, err := p.findStruct(name)
+	if err != nil {
+		return err
+	}
+
+	fields := append([]*ast.Field(nil), st.Fields.List...)
+	for _, op := range ops {
+		if err := op(st); err != nil {
+			return fmt.Errorf("struct %s: %w", name, err)
+		}
+	}
+
+	p.dropRemoved(fields, st)
+	return nil
+}
+
+// dropRemoved removes comments and lines of fields which were removed from
+// st, so no blank lines are left in their place.
+func (p *Patcher) dropRemoved(fields []*ast.Field, st *ast.StructType) {
+	kept := make(map[*ast.Field]bool, len(st.Fields.List))
+	for _, field := range st.Fields.List {
+		kept[field] = true
+	}
+
+	removed := make(map[*ast.CommentGroup]bool)
+	for _, field := range fields {
+		if kept[field] {
+			continue
+		}
+		start, end := field.Pos(), field.End()
+		if field.Doc != nil {
+			removed[field.Doc] = true
+			start = field.Doc.Pos()
+		}
+		if field.Comment != nil {
+			removed[field.Comment] = true
+			end = field.Comment.End()
+		}
+		p.mergeLines(start, end)
+	}
+	if len(removed) == 0 {
+		return
+	}
+
+	comments := p.file.Comments[:0]
+	for _, cg := range p.file.Comments {
+		if !removed[cg] {
+			comments = append(comments, cg)
+		}
+	}
+	p.file.Comments = comments
+}
+
+// Source returns formatted patched source.
+func (p *Patcher) Source() ([]byte, error) {
+	var b bytes.Buffer
+	if err := format.Node(&b, p.fset, p.file); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// RenameStruct renames struct type declaration and references to it in the
+// file, including methods receivers and composite types like []Old or
+// map[string]*Old. Doc comment of the struct starting with the old name
+// starts with the new one.
+func (p *Patcher) RenameStruct(oldName, newName string) error {
+	if !token.IsIdentifier(newName) {
+		return fmt.Errorf("invalid struct name %q", newName)
+	}
+	if _, err := p.findStruct(oldName); err != nil {
+		return err
+	}
+	if obj := p.file.Scope.Lookup(newName); obj != nil {
+		return fmt.Errorf("%s %s already exists", obj.Kind, newName)
+	}
+
+	decl := p.file.Scope.Lookup(oldName)
+	// parser resolves keys of composite literals as identifiers, but they
+	// are field names
+	keys := make(map[*ast.Ident]bool)
+	ast.Inspect(p.file, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.CompositeLit:
+			for _, elt := range node.Elts {
+				if kv, ok := elt.(*ast.KeyValueExpr); ok {
+					if key, ok := kv.Key.(*ast.Ident); ok {
+						keys[key] = true
+					}
+				}
+			}
+		case *ast.Ident:
+			if node.Obj == decl && !keys[node] {
+				node.Name = newName
+			}
+		}
+		return true
+	})
+
+	delete(p.file.Scope.Objects, oldName)
+	decl.Name = newName
+	p.file.Scope.Objects[newName] = decl
+
+	// doc comment of single spec declaration belongs to GenDecl
+	spec := decl.Decl.(*ast.TypeSpec)
+	doc := spec.Doc
+	for _, d := range p.file.Decls {
+		if gen, ok := d.(*ast.GenDecl); ok && doc == nil && len(gen.Specs) == 1 && gen.Specs[0] == spec {
+			doc = gen.Doc
+		}
+	}
+	if doc != nil {
+		c := doc.List[0]
+		rest := strings.TrimPrefix(c.Text, "// "+oldName)
+		r, _ := utf8.DecodeRuneInString(rest)
+		if rest != c.Text && r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			c.Text = "// " + newName + rest
+		}
+	}
+	return nil
+}
+
+// ChangeMethodReturn replaces type of the first result of method with name,
+// e.g. response type of func (vk *VK) UsersGet(params Params) (response
+// UsersGetResponse, err error). Method body is kept as is.
+func (p *Patcher) ChangeMethodReturn(methodName, newReturnType string) error {
+	typ, err := parser.ParseExpr(newReturnType)
+	if err != nil {
+		return fmt.Errorf("method %s: invalid type %q: %w", methodName, newReturnType, err)
+	}
+
+	var fn *ast.FuncDecl
+	for _, decl := range p.file.Decls {
+		decl, ok := decl.(*ast.FuncDecl)
+		if !ok || decl.Recv == nil || decl.Name.Name != methodName {
+			continue
+		}
+		if fn != nil {
+			return fmt.Errorf("method %s is declared for several types", methodName)
+		}
+		fn = decl
+	}
+	if fn == nil {
+		return fmt.Errorf("method %s not found", methodName)
+	}
+
+	results := fn.Type.Results
+	if results == nil || len(results.List) == 0 {
+		return fmt.Errorf("method %s has no results", methodName)
+	}
+	result := results.List[0]
+	if len(result.Names) > 1 {
+		return fmt.Errorf("method %s: result %s shares declaration with other results", methodName, result.Names[0].Name)
+	}
+	// parsed type has positions of its own source, they are moved to
+	// position of the old type, so the printer keeps results on one line
+	setPos(typ, result.Type.Pos())
+	result.Type = typ
+	return nil
+}
+
+// EmbedStruct inserts embedded field of struct type embeddedType declared
+// in the file before fields of targetStruct, e.g. BaseObject, so its fields
+// are promoted, also by encoding/json. If removeRedundant is set, fields of
+// targetStruct declared by embeddedType too are removed; they must have the
+// same type and json tag, since explicit fields would shadow promoted ones.
+func (p *Patcher) EmbedStruct(targetStruct, embeddedType string, removeRedundant bool) error {
+	embedded, err := p.findStruct(embeddedType)
+	if err != nil {
+		return err
+	}
+	if targetStruct == embeddedType {
+		return fmt.Errorf("struct %s can't embed itself", targetStruct)
+	}
+
+	return p.PatchStruct(targetStruct, func(st *ast.StructType) error {
+		if field, _ := findField(st, embeddedType); field != nil {
+			return fmt.Errorf("field %s already exists", embeddedType)
+		}
+		for _, field := range st.Fields.List {
+			if len(field.Names) == 0 && embeddedName(field.Type) == embeddedType {
+				return fmt.Errorf("%s is already embedded", embeddedType)
+			}
+		}
+
+		if removeRedundant {
+			for _, field := range embedded.Fields.List {
+				for _, ident := range field.Names {
+					if err := p.removeRedundantField(st, ident.Name, field); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		ident := ast.NewIdent(embeddedType)
+		ident.NamePos = st.Fields.Opening + 1
+		st.Fields.List = append([]*ast.Field{{Type: ident}}, st.Fields.List...)
+		return nil
+	})
+}
+
+// removeRedundantField removes field with name from st if it matches
+// promoted field of embedded struct.
+func (p *Patcher) removeRedundantField(st *ast.StructType, name string, promoted *ast.Field) error {
+	field, idx := findField(st, name)
+	if field == nil {
+		return nil
+	}
+	if len(field.Names) > 1 {
+		return fmt.Errorf("field %s shares declaration with other fields", name)
+	}
+	if types.ExprString(field.Type) != types.ExprString(promoted.Type) {
+		return fmt.Errorf("field %s: type %s differs from embedded %s", name, types.ExprString(field.Type), types.ExprString(promoted.Type))
+	}
+	if jsonTag(field) != jsonTag(promoted) {
+		return fmt.Errorf("field %s: json tag %q differs from embedded %q", name, jsonTag(field), jsonTag(promoted))
+	}
+
+	st.Fields.List = append(st.Fields.List[:idx], st.Fields.List[idx+1:]...)
+	return nil
+}
+
+// mergeLines merges lines from start to end with the following line, so
+// removed node spanning them leaves no blank line.
+func (p *Patcher) mergeLines(start, end token.Pos) {
+	tf := p.fset.File(start)
+	line := tf.Line(start)
+	for n := tf.Line(end) - line + 1; n > 0; n-- {
+		tf.MergeLine(line)
+	}
+}
+
+// embeddedName returns name of embedded field of type expr.
+func embeddedName(expr ast.Expr) string {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		return expr.Name
+	case *ast.SelectorExpr:
+		return expr.Sel.Name
+	}
+	return ""
+}
+
+// jsonTag returns json tag value of the field, empty if there is none.
+func jsonTag(field *ast.Field) string {
+	if field.Tag == nil {
+		return ""
+	}
+	tag, err := strconv.Unquote(field.Tag.Value)
+	if err != nil {
+		return ""
+	}
+	return reflect.StructTag(tag).Get("json")
+}
+
+// setPos sets positions of all nodes of expression to pos.
+func setPos(expr ast.Expr, pos token.Pos) {
+	posType := reflect.TypeOf(token.NoPos)
+	ast.Inspect(expr, func(node ast.Node) bool {
+		if node == nil {
+			return false
+		}
+		v := reflect.ValueOf(node).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			if f := v.Field(i); f.Type() == posType {
+				f.Set(reflect.ValueOf(pos))
+			}
+		}
+		return true
+	})
+}
+
+func (p *Patcher) findStruct(name string) (*ast.StructType, error) {
+	for _, decl := range p.file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if ts.Name.Name != name {
+				continue
+			}
+
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok {
+				return nil, fmt.Errorf("type %s is not a struct", name)
+			}
+			return st, nil
+		}
+	}
+	return nil, fmt.Errorf("struct %s not found", name)
+}
+
+// ChangeField changes type of the struct field.
+func ChangeField(name, goType string) StructOp {
+	return func(st *ast.StructType) error {
+		typ, err := parser.ParseExpr(goType)
+		if err != nil {
+			return fmt.Errorf("field %s: invalid type %q: %w", name, goType, err)
+		}
+
+		field, _ := findField(st, name)
+		if field == nil {
+			return fmt.Errorf("field %s not found", name)
+		}
+		field.Type = typ
+		return nil
+	}
+}
+
+func findField(st *ast.StructType, name string) (*ast.Field, int) {
+	for i, field := range st.Fields.List {
+		for _, ident := range field.Names {
+			if ident.Name == name {
+				return field, i
+			}
+		}
+	}
+	return nil, -1
+}
+
+// AddField appends field to the end of the struct.
+func AddField(name, goType, tag string) StructOp {
+	return func(st *ast.StructType) error {
+		if field, _ := findField(st, name); field != nil {
+			return fmt.Errorf("field %s already exists", name)
+		}
+
+		typ, err := parser.ParseExpr(goType)
+		if err != nil {
+			return fmt.Errorf("field %s: invalid type %q: %w", name, goType, err)
+		}
+
+		// position field before closing brace to keep comments of
+		// the last field in place
+		ident := ast.NewIdent(name)
+		ident.NamePos = st.Fields.Closing
+		field := &ast.Field{
+			Names: []*ast.Ident{ident},
+			Type:  typ,
+		}
+		if tag != "" {
+			field.Tag = &ast.BasicLit{
+				Kind:  token.STRING,
+				Value: "`" + tag + "`",
+			}
+		}
+		st.Fields.List = append(st.Fields.List, field)
+		return nil
+	}
+}
+
+// RemoveField removes field from the struct.
+func RemoveField(name string) StructOp {
+	return func(st *ast.StructType) error {
+		field, idx := findField(st, name)
+		if field == nil {
+			return fmt.Errorf("field %s not found", name)
+		}
+		if len(field.Names) > 1 {
+			return fmt.Errorf("field %s shares declaration with other fields", name)
+		}
+
+		st.Fields.List = append(st.Fields.List[:idx], st.Fields.List[idx+1:]...)
+		return nil
+	}
+}
+
+// ChangeTag replaces struct tag of the field, empty tag removes it. Comments
+// of the field are kept.
+func ChangeTag(name, tag string) StructOp {
+	return func(st *ast.StructType) error {
+		if err := validateTag(tag); err != nil {
+			return fmt.Errorf("field %s: invalid tag %q: %w", name, tag, err)
+		}
+
+		field, _ := findField(st, name)
+		if field == nil {
+			return fmt.Errorf("field %s not found", name)
+		}
+		if tag == "" {
+			field.Tag = nil
+			return nil
+		}
+
+		// keep position of the old tag, so trailing comment stays on the
+		// line of the field
+		pos := field.Type.End()
+		if field.Tag != nil {
+			pos = field.Tag.ValuePos
+		}
+		field.Tag = &ast.BasicLit{
+			ValuePos: pos,
+			Kind:     token.STRING,
+			Value:    "`" + tag + "`",
+		}
+		return nil
+	}
+}
+
+// validateTag checks that tag is in conventional format of
+// reflect.StructTag: space-separated key:"value" pairs.
+func validateTag(tag string) error {
+	if strings.Contains(tag, "`") {
+		return fmt.Errorf("backquote in tag")
+	}
+	for tag != "" {
+		i := 0
+		for i < len(tag) && tag[i] == ' ' {
+			i++
+		}
+		tag = tag[i:]
+		if tag == "" {
+			break
+		}
+
+		i = 0
+		for i < len(tag) && tag[i] > ' ' && tag[i] != ':' && tag[i] != '"' && tag[i] != 0x7f {
+			i++
+		}
+		if i == 0 || i+1 >= len(tag) || tag[i] != ':' || tag[i+1] != '"' {
+			return fmt.Errorf("malformed key:\"value\" pair at %q", tag)
+		}
+		key := tag[:i]
+		tag = tag[i+1:]
+
+		i = 1
+		for i < len(tag) && tag[i] != '"' {
+			if tag[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(tag) {
+			return fmt.Errorf("unterminated value of key %s", key)
+		}
+		if _, err := strconv.Unquote(tag[:i+1]); err != nil {
+			return fmt.Errorf("value of key %s: %w", key, err)
+		}
+		tag = tag[i+1:]
+	}
+	return nil
+}
+
+// SetOmitempty adds or removes omitempty option of json tag of the field.
+func SetOmitempty(name string, omitempty bool) StructOp {
+	return func(st *ast.StructType) error {
+		field, _ := findField(st, name)
+		if field == nil {
+			return fmt.Errorf("field %s not found", name)
+		}
+		if field.Tag == nil {
+			return fmt.Errorf("field %s has no json tag", name)
+		}
+
+		tag, err := strconv.Unquote(field.Tag.Value)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", name, err)
+		}
+		value, ok := reflect.StructTag(tag).Lookup("json")
+		if !ok || value == "-" {
+			return fmt.Errorf("field %s has no json tag", name)
+		}
+
+		opts := strings.Split(value, ",")
+		newOpts := opts[:1]
+		for _, opt := range opts[1:] {
+			if opt != "omitempty" {
+				newOpts = append(newOpts, opt)
+			}
+		}
+		if omitempty {
+			newOpts = append(newOpts, "omitempty")
+		}
+
+		old := `json:` + strconv.Quote(value)
+		tag = strings.Replace(tag, old, `json:`+strconv.Quote(strings.Join(newOpts, ",")), 1)
+		field.Tag.Value = "`" + tag + "`"
+		return nil
+	}
+}
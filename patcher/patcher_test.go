@@ -0,0 +1,263 @@
+package patcher
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+)
+
+// patch applies fn to patcher of src and returns patched source.
+func patch(t *testing.T, src string, fn func(p *Patcher) error) string {
+	t.Helper()
+	p, err := NewPatcher([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fn(p); err != nil {
+		t.Fatal(err)
+	}
+	out, err := p.Source()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestRemoveField(t *testing.T) {
+	src := `package generated
+
+type UsersUser struct {
+	ID int64 ` + "`json:\"id\"`" + `
+	// Deactivated is set for deleted users.
+	Deactivated string ` + "`json:\"deactivated\"`" + ` // deleted or banned
+	FirstName string ` + "`json:\"first_name\"`" + `
+}
+`
+	want := `package generated
+
+type UsersUser struct {
+	ID        int64  ` + "`json:\"id\"`" + `
+	FirstName string ` + "`json:\"first_name\"`" + `
+}
+`
+	got := patch(t, src, func(p *Patcher) error {
+		return p.PatchStruct("UsersUser", RemoveField("Deactivated"))
+	})
+	if got != want {
+		t.Errorf("patched source:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRenameStruct(t *testing.T) {
+	src := `package generated
+
+// UsersUser is VK user.
+type UsersUser struct {
+	Friends []*UsersUser
+}
+
+type (
+	// UsersUserMin is VK user with name only.
+	UsersUserMin struct{}
+	// Users are VK users.
+	Users []UsersUser
+)
+
+// Name of UsersUser.
+func (u UsersUser) Name() string {
+	return UsersUser{}.String()
+}
+`
+	want := `package generated
+
+// UsersUserFull is VK user.
+type UsersUserFull struct {
+	Friends []*UsersUserFull
+}
+
+type (
+	// UsersUserMin is VK user with name only.
+	UsersUserMin struct{}
+	// Users are VK users.
+	Users []UsersUserFull
+)
+
+// Name of UsersUser.
+func (u UsersUserFull) Name() string {
+	return UsersUserFull{}.String()
+}
+`
+	got := patch(t, src, func(p *Patcher) error {
+		return p.RenameStruct("UsersUser", "UsersUserFull")
+	})
+	if got != want {
+		t.Errorf("patched source:\n%s\nwant:\n%s", got, want)
+	}
+
+	got = patch(t, src, func(p *Patcher) error {
+		return p.RenameStruct("UsersUserMin", "UsersUserShort")
+	})
+	if !strings.Contains(got, "\t// UsersUserShort is VK user with name only.\n\tUsersUserShort struct{}\n") {
+		t.Errorf("doc comment of grouped struct is not renamed:\n%s", got)
+	}
+}
+
+func TestAddField(t *testing.T) {
+	src := `package generated
+
+type UsersUser struct {
+	ID int64 ` + "`json:\"id\"`" + ` // user ID
+}
+`
+	want := `package generated
+
+type UsersUser struct {
+	ID  int64           ` + "`json:\"id\"`" + ` // user ID
+	Raw json.RawMessage ` + "`json:\"-\"`" + `
+}
+`
+	got := patch(t, src, func(p *Patcher) error {
+		return p.PatchStruct("UsersUser", AddField("Raw", "json.RawMessage", `json:"-"`))
+	})
+	if got != want {
+		t.Errorf("patched source:\n%s\nwant:\n%s", got, want)
+	}
+
+	p, err := NewPatcher([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.PatchStruct("UsersUserFull", AddField("Raw", "json.RawMessage", "")); err == nil {
+		t.Error("no error for missing struct")
+	}
+	if err := p.PatchStruct("UsersUser", AddField("ID", "int", "")); err == nil {
+		t.Error("no error for existing field")
+	}
+}
+
+func TestChangeTag(t *testing.T) {
+	src := `package generated
+
+type UsersUser struct {
+	ID int64 ` + "`json:\"id\"`" + ` // user ID
+	FirstName string ` + "`json:\"first_name\"`" + `
+}
+`
+	want := `package generated
+
+type UsersUser struct {
+	ID        int64  ` + "`json:\"id,string\"`" + ` // user ID
+	FirstName string ` + "`json:\"first_name\"`" + `
+}
+`
+	got := patch(t, src, func(p *Patcher) error {
+		return p.PatchStruct("UsersUser", ChangeTag("ID", `json:"id,string"`))
+	})
+	if got != want {
+		t.Errorf("patched source:\n%s\nwant:\n%s", got, want)
+	}
+
+	p, err := NewPatcher([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.PatchStruct("UsersUser", ChangeTag("ID", `json:id`)); err == nil {
+		t.Error("malformed tag is accepted")
+	}
+}
+
+func TestChangeMethodReturn(t *testing.T) {
+	src := `package generated
+
+func (vk *VK) UsersGet(params Params) (response UsersGetResponse, err error) {
+	err = vk.RequestUnmarshal("users.get", params, &response)
+	return
+}
+`
+	want := `package generated
+
+func (vk *VK) UsersGet(params Params) (response []UsersUserFull, err error) {
+	err = vk.RequestUnmarshal("users.get", params, &response)
+	return
+}
+`
+	got := patch(t, src, func(p *Patcher) error {
+		return p.ChangeMethodReturn("UsersGet", "[]UsersUserFull")
+	})
+	if got != want {
+		t.Errorf("patched source:\n%s\nwant:\n%s", got, want)
+	}
+
+	p, err := NewPatcher([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.ChangeMethodReturn("UsersSearch", "UsersUser"); err == nil {
+		t.Error("missing method is not reported")
+	}
+}
+
+func TestEmbedStruct(t *testing.T) {
+	src := `package generated
+
+type BaseObject struct {
+	ID    int64  ` + "`json:\"id\"`" + `
+	Title string ` + "`json:\"title\"`" + `
+}
+
+type DatabaseCity struct {
+	ID    int64  ` + "`json:\"id\"`" + `
+	Title string ` + "`json:\"title\"`" + ` // city name
+	Area  string ` + "`json:\"area\"`" + `
+}
+`
+	want := `package generated
+
+type BaseObject struct {
+	ID    int64  ` + "`json:\"id\"`" + `
+	Title string ` + "`json:\"title\"`" + `
+}
+
+type DatabaseCity struct {
+	BaseObject
+	Area string ` + "`json:\"area\"`" + `
+}
+`
+	got := patch(t, src, func(p *Patcher) error {
+		return p.EmbedStruct("DatabaseCity", "BaseObject", true)
+	})
+	if got != want {
+		t.Errorf("patched source:\n%s\nwant:\n%s", got, want)
+	}
+
+	// encoding/json decodes promoted fields of untagged embedded struct
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", got, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := (&types.Config{Importer: importer.Default()}).Check("generated", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	city := pkg.Scope().Lookup("DatabaseCity").Type()
+	for _, name := range []string{"ID", "Title"} {
+		field, index, _ := types.LookupFieldOrMethod(city, false, pkg, name)
+		if field == nil || len(index) != 2 {
+			t.Errorf("field %s is not promoted: %v", name, index)
+		}
+	}
+
+	shadowing := strings.Replace(src, "`json:\"title\"` // city name", "`json:\"name\"` // city name", 1)
+	p, err := NewPatcher([]byte(shadowing))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.EmbedStruct("DatabaseCity", "BaseObject", true); err == nil {
+		t.Error("field with other json tag is removed")
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cqln/vkgen/schema"
+)
+
+// roundTripTests are fixtures of one response type.
+type roundTripTests struct {
+	gresponse string
+	// names are fixture names in schema order, payloads are keyed by them.
+	names    []string
+	payloads map[string][]byte
+}
+
+// generateTests generates tests unmarshaling fixtures of tests directory
+// into response types and checking they survive marshaling. Fixtures hold
+// values of response field and are named after keys of MethodResponses,
+// e.g. users.get.json or groups.getByIdExtended.json; types without
+// fixtures get no tests.
+func (g Generator) generateTests() error {
+	if g.tests == "" {
+		return nil
+	}
+
+	sch, err := g.readSchema(schema.MethodsSchema)
+	if err != nil {
+		return err
+	}
+	methods, err := g.parseMethods(sch)
+	if err != nil {
+		return err
+	}
+
+	var order []*roundTripTests
+	byType := make(map[string]*roundTripTests)
+	for _, method := range methods {
+		for _, response := range method.Responses {
+			_, postfix, gresponse, err := g.methodVariant(method, response)
+			if err != nil {
+				return err
+			}
+			name := method.Name + postfix
+			payload, err := ioutil.ReadFile(filepath.Join(g.tests, name+".json"))
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if !json.Valid(payload) {
+				return fmt.Errorf("%s: fixture is not valid JSON", name)
+			}
+
+			tests, ok := byType[gresponse]
+			if !ok {
+				tests = &roundTripTests{gresponse: gresponse, payloads: make(map[string][]byte)}
+				byType[gresponse] = tests
+				order = append(order, tests)
+			}
+			tests.names = append(tests.names, name)
+			tests.payloads[name] = bytes.TrimSpace(payload)
+		}
+	}
+
+	b := bytes.NewBuffer(nil)
+	b.WriteString(genPrefix + "\n\npackage " + pkgName + "\n\n")
+	b.WriteString("import (\n")
+	b.WriteString("\t\"encoding/json\"\n")
+	b.WriteString("\t\"reflect\"\n")
+	b.WriteString("\t\"testing\"\n")
+	b.WriteString(")\n\n")
+	b.WriteString("// roundTrip unmarshals payload into v, marshals it and unmarshals result\n")
+	b.WriteString("// into w, which must equal v.\n")
+	b.WriteString("func roundTrip(t *testing.T, payload string, v, w interface{}) {\n")
+	b.WriteString("\tt.Helper()\n")
+	b.WriteString("\tif err := json.Unmarshal([]byte(payload), v); err != nil {\n")
+	b.WriteString("\t\tt.Fatalf(\"unmarshal: %v\", err)\n")
+	b.WriteString("\t}\n")
+	b.WriteString("\tdata, err := json.Marshal(v)\n")
+	b.WriteString("\tif err != nil {\n")
+	b.WriteString("\t\tt.Fatalf(\"marshal: %v\", err)\n")
+	b.WriteString("\t}\n")
+	b.WriteString("\tif err := json.Unmarshal(data, w); err != nil {\n")
+	b.WriteString("\t\tt.Fatalf(\"unmarshal marshaled: %v\", err)\n")
+	b.WriteString("\t}\n")
+	b.WriteString("\tif !reflect.DeepEqual(v, w) {\n")
+	b.WriteString("\t\tt.Errorf(\"value changed by round trip:\\n%s\", data)\n")
+	b.WriteString("\t}\n")
+	b.WriteString("}\n")
+	for _, tests := range order {
+		b.WriteString("\nfunc TestUnmarshal" + tests.gresponse + "(t *testing.T) {\n")
+		b.WriteString("\tfor name, payload := range map[string]string{\n")
+		for _, name := range tests.names {
+			b.WriteString("\t\t" + strconv.Quote(name) + ": " + stringLiteral(string(tests.payloads[name])) + ",\n")
+		}
+		b.WriteString("\t} {\n")
+		b.WriteString("\t\tname, payload := name, payload\n")
+		b.WriteString("\t\tt.Run(name, func(t *testing.T) {\n")
+		b.WriteString("\t\t\troundTrip(t, payload, new(" + tests.gresponse + "), new(" + tests.gresponse + "))\n")
+		b.WriteString("\t\t})\n")
+		b.WriteString("\t}\n")
+		b.WriteString("}\n")
+	}
+	return g.writeSource(pkgName+"/responses.gen_test.go", b)
+}
+
+// testFixtures returns paths of fixtures of tests directory, they are
+// inputs of generated code.
+func (g Generator) testFixtures() ([]string, error) {
+	if g.tests == "" {
+		return nil, nil
+	}
+	return filepath.Glob(filepath.Join(g.tests, "*.json"))
+}
+
+// stringLiteral returns raw string literal of s if it can be one.
+func stringLiteral(s string) string {
+	if strings.ContainsAny(s, "`\r") {
+		return strconv.Quote(s)
+	}
+	return "`" + s + "`"
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cqln/vkgen/patcher"
+	"gopkg.in/yaml.v2"
+)
+
+// Rules overrides types of generated struct fields:
+// output file -> struct -> field -> new type.
+//
+// Type may refer to FlexBool, which is generated if used, to accept both
+// booleans and 1/0 integers VK returns inconsistently.
+//
+// Type may be followed by ",omitempty" or ",!omitempty" to add or remove
+// omitempty option of json tag and ",emptyarray" to unmarshal empty JSON
+// array as missing value, type may be empty to keep it.
+//
+// Rule ",embed" of field named after struct type of the same file, e.g.
+// BaseObject, embeds the struct and removes fields it declares.
+type Rules map[string]map[string]map[string]string
+
+// kekRules are built-in rules, rules file is merged over them.
+var kekRules = Rules{}
+
+// LoadRules reads rules from JSON or YAML file by its extension.
+func LoadRules(path string) (Rules, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules Rules
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &rules); err != nil {
+			// errors of yaml package carry line numbers in messages
+			if m := yamlErrorLine.FindStringSubmatch(err.Error()); m != nil {
+				line, _ := strconv.Atoi(m[1])
+				return nil, fmt.Errorf("%s:%s: %w", path, lineContext(data, lineOffset(data, line)), err)
+			}
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	default:
+		if err := json.Unmarshal(data, &rules); err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			switch {
+			case errors.As(err, &syntaxErr):
+				return nil, fmt.Errorf("%s:%s: %w", path, lineContext(data, syntaxErr.Offset), err)
+			case errors.As(err, &typeErr):
+				return nil, fmt.Errorf("%s:%s: %w", path, lineContext(data, typeErr.Offset), err)
+			}
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	if err := rules.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// yamlErrorLine matches line number of yaml error, e.g. "yaml: line 3:".
+var yamlErrorLine = regexp.MustCompile(`\bline ([0-9]+):`)
+
+// lineOffset returns offset of the line by its number starting at 1.
+func lineOffset(data []byte, line int) int64 {
+	offset := 0
+	for ; line > 1; line-- {
+		i := bytes.IndexByte(data[offset:], '\n')
+		if i < 0 {
+			return int64(len(data))
+		}
+		offset += i + 1
+	}
+	return int64(offset)
+}
+
+// lineContext returns line number and text of the line at offset.
+func lineContext(data []byte, offset int64) string {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	line := bytes.Count(data[:offset], []byte("\n")) + 1
+	start := bytes.LastIndexByte(data[:offset], '\n') + 1
+	end := bytes.IndexByte(data[offset:], '\n')
+	if end < 0 {
+		end = len(data)
+	} else {
+		end += int(offset)
+	}
+	return fmt.Sprintf("%d: %s", line, bytes.TrimSpace(data[start:end]))
+}
+
+func (r Rules) validate() error {
+	for file, structs := range r {
+		for structName, fields := range structs {
+			if !token.IsIdentifier(structName) {
+				return fmt.Errorf("%s: invalid struct name %q", file, structName)
+			}
+			for field, rule := range fields {
+				if !token.IsIdentifier(field) {
+					return fmt.Errorf("%s: %s: invalid field name %q", file, structName, field)
+				}
+				if _, err := parseFieldRule(rule); err != nil {
+					return fmt.Errorf("%s: %s.%s: %w", file, structName, field, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// fieldRule is parsed rule of struct field.
+type fieldRule struct {
+	// typ is new type of field, empty if unchanged.
+	typ string
+	// omitempty adds or removes omitempty option of json tag, nil if
+	// unchanged.
+	omitempty *bool
+	// emptyArray makes field unmarshal empty JSON array as missing value,
+	// since VK returns [] instead of empty objects.
+	emptyArray bool
+	// embed embeds struct named after field instead of changing field.
+	embed bool
+}
+
+// parseFieldRule parses field rule: type followed by comma-separated
+// options.
+func parseFieldRule(rule string) (fieldRule, error) {
+	parts := strings.Split(rule, ",")
+	fr := fieldRule{typ: parts[0]}
+	for _, opt := range parts[1:] {
+		switch opt {
+		case "omitempty", "!omitempty":
+			set := opt == "omitempty"
+			fr.omitempty = &set
+		case "emptyarray":
+			fr.emptyArray = true
+		case "embed":
+			fr.embed = true
+		default:
+			return fr, fmt.Errorf("invalid option %q", opt)
+		}
+	}
+
+	if len(parts) == 1 && fr.typ == "" {
+		return fr, fmt.Errorf("empty rule")
+	}
+	if fr.embed && (len(parts) != 2 || fr.typ != "") {
+		return fr, fmt.Errorf("embed can't be combined with type or other options")
+	}
+	if fr.typ != "" {
+		if _, err := parser.ParseExpr(fr.typ); err != nil {
+			return fr, fmt.Errorf("invalid type %q", fr.typ)
+		}
+	}
+	return fr, nil
+}
+
+// emptyArrayFields returns names of fields of the struct in the file which
+// unmarshal empty arrays as missing values.
+func (r Rules) emptyArrayFields(file, structName string) map[string]bool {
+	fields := make(map[string]bool)
+	for field, rule := range r[file][structName] {
+		if fr, err := parseFieldRule(rule); err == nil && fr.emptyArray {
+			fields[field] = true
+		}
+	}
+	return fields
+}
+
+// usesType reports whether types of rules refer to type with name, e.g.
+// generated helper type FlexBool.
+func (r Rules) usesType(name string) bool {
+	for _, structs := range r {
+		for _, fields := range structs {
+			for _, rule := range fields {
+				fr, err := parseFieldRule(rule)
+				if err != nil || fr.typ == "" {
+					continue
+				}
+				expr, err := parser.ParseExpr(fr.typ)
+				if err != nil {
+					continue
+				}
+				found := false
+				ast.Inspect(expr, func(node ast.Node) bool {
+					if ident, ok := node.(*ast.Ident); ok && ident.Name == name {
+						found = true
+					}
+					return !found
+				})
+				if found {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Merge returns rules with other merged over r.
+func (r Rules) Merge(other Rules) Rules {
+	merged := make(Rules)
+	for _, rules := range []Rules{r, other} {
+		for file, structs := range rules {
+			if merged[file] == nil {
+				merged[file] = make(map[string]map[string]string)
+			}
+			for structName, fields := range structs {
+				if merged[file][structName] == nil {
+					merged[file][structName] = make(map[string]string)
+				}
+				for field, typ := range fields {
+					merged[file][structName][field] = typ
+				}
+			}
+		}
+	}
+	return merged
+}
+
+// patchSource applies struct rules of the file to src.
+func patchSource(src []byte, structs map[string]map[string]string) ([]byte, error) {
+	p, err := patcher.NewPatcher(src)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for name := range structs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var ops []patcher.StructOp
+		var embeds []string
+		for field, rule := range structs[name] {
+			fr, err := parseFieldRule(rule)
+			if err != nil {
+				return nil, fmt.Errorf("struct %s: field %s: %w", name, field, err)
+			}
+			if fr.embed {
+				embeds = append(embeds, field)
+				continue
+			}
+			if fr.typ != "" {
+				ops = append(ops, patcher.ChangeField(field, fr.typ))
+			}
+			if fr.omitempty != nil {
+				ops = append(ops, patcher.SetOmitempty(field, *fr.omitempty))
+			}
+		}
+		if err := p.PatchStruct(name, ops...); err != nil {
+			return nil, err
+		}
+
+		// embedded structs are inserted in reverse order, so they are
+		// sorted in the struct
+		sort.Sort(sort.Reverse(sort.StringSlice(embeds)))
+		for _, embedded := range embeds {
+			if err := p.EmbedStruct(name, embedded, true); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return p.Source()
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeRules writes rules file with name to temporary directory and
+// returns its path.
+func writeRules(t *testing.T, name, content string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "vkgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadRules(t *testing.T) {
+	want := Rules{"objects.gen.go": {"UsersUser": {"ID": "int,omitempty"}}}
+	for name, content := range map[string]string{
+		"rules.json": `{"objects.gen.go": {"UsersUser": {"ID": "int,omitempty"}}}`,
+		"rules.yaml": "objects.gen.go:\n  UsersUser:\n    ID: int,omitempty\n",
+		"rules.yml":  "objects.gen.go: {UsersUser: {ID: \"int,omitempty\"}}\n",
+	} {
+		rules, err := LoadRules(writeRules(t, name, content))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(rules, want) {
+			t.Errorf("%s: rules %v, want %v", name, rules, want)
+		}
+	}
+}
+
+func TestLoadRulesMalformed(t *testing.T) {
+	for name, test := range map[string]struct {
+		content string
+		context string
+	}{
+		"syntax.json": {"{\n  \"objects.gen.go\": {\n    \"UsersUser\" {}\n  }\n}\n", `:3: "UsersUser" {}: `},
+		"type.json":   {"{\n  \"objects.gen.go\": {\n    \"UsersUser\": {\"ID\": 1}\n  }\n}\n", `:3: "UsersUser": {"ID": 1}: `},
+		"syntax.yaml": {"objects.gen.go:\n  UsersUser:\n    ID: [int\n", ":3: ID: [int: "},
+		"type.yaml":   {"objects.gen.go:\n  UsersUser:\n    ID: [int]\n", ":3: ID: [int]: "},
+	} {
+		path := writeRules(t, name, test.content)
+		_, err := LoadRules(path)
+		if err == nil {
+			t.Errorf("%s: no error", name)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), path+test.context) {
+			t.Errorf("%s: error %q lacks line context %q", name, err, test.context)
+		}
+	}
+}
+
+func TestLoadRulesInvalidRule(t *testing.T) {
+	path := writeRules(t, "rules.yaml", "objects.gen.go:\n  UsersUser:\n    ID: int,sometimes\n")
+	_, err := LoadRules(path)
+	if err == nil || !strings.Contains(err.Error(), `UsersUser.ID: invalid option "sometimes"`) {
+		t.Errorf("error of invalid option: %v", err)
+	}
+}
@@ -0,0 +1,27 @@
+package schema
+
+import "github.com/tidwall/gjson"
+
+type ErrorDefinition struct {
+	Name        string
+	Code        int64
+	Description *string
+}
+
+func (p *Parser) ParseErrors(schema []byte) ([]ErrorDefinition, error) {
+	var defs []ErrorDefinition
+	gjson.ParseBytes(schema).Get("errors").ForEach(func(name, errData gjson.Result) bool {
+		def := ErrorDefinition{
+			Name: name.String(),
+			Code: errData.Get("code").Int(),
+		}
+		if desc := errData.Get("description"); desc.Exists() {
+			d := desc.String()
+			def.Description = &d
+		}
+		defs = append(defs, def)
+		return true
+	})
+
+	return defs, nil
+}
@@ -1,17 +1,44 @@
 package schema
 
-import "github.com/tidwall/gjson"
+import (
+	"sort"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
 
 type MethodDefinition struct {
 	Name        string
 	Description *string
 	AccessType  []string
 	Parameters  []MethodParam
-	Responses   []ObjectDefinition
+	Responses   []MethodResponse
+
+	// Deprecated is set for deprecated methods, DeprecatedMessage is
+	// optional explanation.
+	Deprecated        bool
+	DeprecatedMessage string
+
+	// Errors are names of errors declared in errors schema.
+	Errors []string
+}
+
+// MethodResponse is response variant of method.
+type MethodResponse struct {
+	ObjectDefinition
+
+	// Variant distinguishes additional responses of method, e.g. "extended"
+	// for "extendedResponse". It is empty for default "response".
+	Variant string
+
+	// Definition is name of referenced definition of responses schema,
+	// empty if response isn't a reference to it.
+	Definition string
 }
 
 type MethodParam struct {
-	Name string
+	Name     string
+	Required bool
 	ObjectExpr
 }
 
@@ -24,6 +51,11 @@ func (p *Parser) ParseMethods(schema []byte) ([]MethodDefinition, error) {
 		}
 		defs = append(defs, def)
 	}
+	disambiguateVariants(defs)
+	// generated code must not depend on order of methods in schema
+	sort.SliceStable(defs, func(i, j int) bool {
+		return defs[i].Name < defs[j].Name
+	})
 
 	return defs, nil
 }
@@ -35,6 +67,7 @@ func (p *Parser) parseMethod(method gjson.Result) (MethodDefinition, error) {
 		d := desc.String()
 		mdef.Description = &d
 	}
+	mdef.Deprecated, mdef.DeprecatedMessage = parseDeprecated(method)
 	var access []string
 	for _, acctype := range method.Get("access_token_type").Array() {
 		access = append(access, acctype.String())
@@ -48,10 +81,15 @@ func (p *Parser) parseMethod(method gjson.Result) (MethodDefinition, error) {
 		}
 		mdef.Parameters = append(mdef.Parameters, MethodParam{
 			Name:       param.Get("name").String(),
+			Required:   param.Get("required").Bool(),
 			ObjectExpr: paramExpr,
 		})
 	}
 
+	for _, e := range method.Get("errors").Array() {
+		mdef.Errors = append(mdef.Errors, resolveReferenceName(e.Get("$ref").String()))
+	}
+
 	var err error
 	method.Get("responses").ForEach(func(respName, respData gjson.Result) bool {
 		expr, parseErr := p.parseObjectExpression(respData)
@@ -59,12 +97,46 @@ func (p *Parser) parseMethod(method gjson.Result) (MethodDefinition, error) {
 			err = parseErr
 			return false
 		}
-		mdef.Responses = append(mdef.Responses, ObjectDefinition{
-			Name: respName.String(),
-			Expr: expr,
-		})
+		resp := MethodResponse{
+			ObjectDefinition: ObjectDefinition{
+				Name: respName.String(),
+				Expr: expr,
+			},
+			Variant: strings.TrimSuffix(respName.String(), "Response"),
+		}
+		if resp.Variant == "response" {
+			resp.Variant = ""
+		}
+		if ref := respData.Get("$ref").String(); strings.HasPrefix(ref, string(ResponsesSchema)+"#") {
+			resp.Definition = resolveReferenceName(ref)
+		}
+		mdef.Responses = append(mdef.Responses, resp)
 		return true
 	})
 
 	return mdef, err
 }
+
+// disambiguateVariants renames response variants which clash with other
+// methods, e.g. "keys" variant of storage.get and storage.getKeys. Variant
+// is taken from response definition name between method name and
+// "_response", e.g. "with_keys" of storage_get_with_keys_response.
+func disambiguateVariants(defs []MethodDefinition) {
+	names := make(map[string]bool, len(defs))
+	for _, def := range defs {
+		names[strings.ToLower(def.Name)] = true
+	}
+
+	for _, def := range defs {
+		prefix := strings.ReplaceAll(def.Name, ".", "_") + "_"
+		for i, resp := range def.Responses {
+			if resp.Variant == "" || !names[strings.ToLower(def.Name+resp.Variant)] {
+				continue
+			}
+			variant := strings.TrimSuffix(strings.TrimPrefix(resp.Definition, prefix), "_response")
+			if variant != resp.Definition && variant != "" {
+				def.Responses[i].Variant = variant
+			}
+		}
+	}
+}
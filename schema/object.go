@@ -2,6 +2,7 @@ package schema
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/tidwall/gjson"
 )
@@ -14,19 +15,53 @@ type ObjectDefinition struct {
 type ObjectExpr struct {
 	Type        string
 	Description *string
+	Default     *string
 	Ref         func() (ObjectDefinition, error)
 	Properties  []ObjectDefinition
+	Required    []string
 	AllOf       []ObjectExpr
 	OneOf       []ObjectExpr
 	Enum        []interface{}
 	EnumNames   []string
 	ArrayOf     *ObjectExpr
+	MaxItems    *int64
 	IsBaseType  bool
 	IsReference bool
 	IsAllOf     bool
 	IsOneOf     bool
 	IsEnum      bool
 	//IsArray     bool
+
+	// Deprecated is set for deprecated entries, DeprecatedMessage is
+	// optional explanation.
+	Deprecated        bool
+	DeprecatedMessage string
+
+	// AdditionalProperties is type of values of string-keyed map, empty
+	// expression if values are not restricted.
+	AdditionalProperties *ObjectExpr
+
+	// PatternProperties are types of values of properties with names
+	// matching patterns, names of definitions are patterns.
+	PatternProperties []ObjectDefinition
+
+	// Format of string or integer, e.g. "date-time" or "int32".
+	Format string
+
+	// Discriminator selects oneOf branch by property value.
+	Discriminator *Discriminator
+
+	// Constraints of numbers and string lengths.
+	Minimum   *float64
+	Maximum   *float64
+	MinLength *int64
+	MaxLength *int64
+}
+
+type Discriminator struct {
+	PropertyName string
+	// Mapping maps property values to names of referenced definitions.
+	Mapping map[string]string
 }
 
 func (p *Parser) ParseObjects(schema []byte) ([]ObjectDefinition, error) {
@@ -45,6 +80,10 @@ func (p *Parser) ParseObjects(schema []byte) ([]ObjectDefinition, error) {
 		})
 		return true
 	})
+	// generated code must not depend on order of definitions in schema
+	sort.SliceStable(defs, func(i, j int) bool {
+		return defs[i].Name < defs[j].Name
+	})
 	return defs, err
 }
 
@@ -56,6 +95,33 @@ func (p *Parser) parseObjectExpression(obj gjson.Result) (ObjectExpr, error) {
 		expr.Description = &d
 	}
 
+	if def := obj.Get("default"); def.Exists() {
+		d := def.String()
+		if def.Type != gjson.String {
+			d = def.Raw
+		}
+		expr.Default = &d
+	}
+
+	expr.Deprecated, expr.DeprecatedMessage = parseDeprecated(obj)
+	expr.Format = obj.Get("format").String()
+	if min := obj.Get("minimum"); min.Exists() {
+		m := min.Float()
+		expr.Minimum = &m
+	}
+	if max := obj.Get("maximum"); max.Exists() {
+		m := max.Float()
+		expr.Maximum = &m
+	}
+	if minLength := obj.Get("minLength"); minLength.Exists() {
+		m := minLength.Int()
+		expr.MinLength = &m
+	}
+	if maxLength := obj.Get("maxLength"); maxLength.Exists() {
+		m := maxLength.Int()
+		expr.MaxLength = &m
+	}
+
 	var err error
 	if props := obj.Get("properties"); props.Exists() {
 		props.ForEach(func(propName, propData gjson.Result) bool {
@@ -76,6 +142,13 @@ func (p *Parser) parseObjectExpression(obj gjson.Result) (ObjectExpr, error) {
 		return expr, err
 	}
 
+	// parameters mark themselves required by boolean
+	if required := obj.Get("required"); required.IsArray() {
+		for _, req := range required.Array() {
+			expr.Required = append(expr.Required, req.String())
+		}
+	}
+
 	if ref := obj.Get("$ref"); ref.Exists() {
 		refFn := func() (ObjectDefinition, error) {
 			return p.resolveReference(ref.String())
@@ -100,6 +173,34 @@ func (p *Parser) parseObjectExpression(obj gjson.Result) (ObjectExpr, error) {
 		return expr, nil
 	}
 
+	switch additional := obj.Get("additionalProperties"); additional.Type {
+	case gjson.True:
+		expr.AdditionalProperties = &ObjectExpr{}
+	case gjson.JSON:
+		valueExpr, parseErr := p.parseObjectExpression(additional)
+		if parseErr != nil {
+			return expr, parseErr
+		}
+		expr.AdditionalProperties = &valueExpr
+	}
+
+	var patternErr error
+	obj.Get("patternProperties").ForEach(func(pattern, valueData gjson.Result) bool {
+		valueExpr, parseErr := p.parseObjectExpression(valueData)
+		if parseErr != nil {
+			patternErr = parseErr
+			return false
+		}
+		expr.PatternProperties = append(expr.PatternProperties, ObjectDefinition{
+			Name: pattern.String(),
+			Expr: valueExpr,
+		})
+		return true
+	})
+	if patternErr != nil {
+		return expr, patternErr
+	}
+
 	typ := obj.Get("type")
 	if !typ.Exists() {
 		//pp.Println(obj)
@@ -152,6 +253,17 @@ func (p *Parser) parseObjectExpression(obj gjson.Result) (ObjectExpr, error) {
 				expr.OneOf = append(expr.OneOf, itemObjExpr)
 			}
 			expr.IsOneOf = true
+
+			if disc := obj.Get("discriminator"); disc.Exists() {
+				expr.Discriminator = &Discriminator{
+					PropertyName: disc.Get("propertyName").String(),
+					Mapping:      make(map[string]string),
+				}
+				disc.Get("mapping").ForEach(func(value, ref gjson.Result) bool {
+					expr.Discriminator.Mapping[value.String()] = resolveReferenceName(ref.String())
+					return true
+				})
+			}
 			return expr, nil
 		}
 	case "array":
@@ -164,6 +276,10 @@ func (p *Parser) parseObjectExpression(obj gjson.Result) (ObjectExpr, error) {
 		if parseErr != nil {
 			return expr, parseErr
 		}
+		if maxItems := obj.Get("maxItems"); maxItems.Exists() {
+			m := maxItems.Int()
+			expr.MaxItems = &m
+		}
 		expr.IsBaseType = true
 		//expr.IsArray = true
 		expr.ArrayOf = &arrayType
@@ -0,0 +1,27 @@
+package schema
+
+import "testing"
+
+func TestParsePatternProperties(t *testing.T) {
+	objects := []byte(`{
+  "definitions": {
+    "messages_delete_result": {
+      "type": "object",
+      "patternProperties": {
+        "^[0-9]+$": {"type": "integer"}
+      }
+    }
+  }
+}`)
+	defs, err := NewParser(objects).ParseObjects(objects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(defs) != 1 {
+		t.Fatalf("parsed %d definitions", len(defs))
+	}
+	patterns := defs[0].Expr.PatternProperties
+	if len(patterns) != 1 || patterns[0].Name != "^[0-9]+$" || patterns[0].Expr.Type != "integer" {
+		t.Errorf("pattern properties %+v", patterns)
+	}
+}
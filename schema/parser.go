@@ -8,7 +8,8 @@ import (
 )
 
 type Parser struct {
-	objects gjson.Result
+	objects   gjson.Result
+	responses gjson.Result
 }
 
 func NewParser(objectsSchema []byte) *Parser {
@@ -17,6 +18,12 @@ func NewParser(objectsSchema []byte) *Parser {
 	}
 }
 
+// SetResponses sets responses schema used to resolve references from
+// methods to responses. Without it references resolve to names only.
+func (p *Parser) SetResponses(responsesSchema []byte) {
+	p.responses = gjson.ParseBytes(responsesSchema)
+}
+
 func (p *Parser) resolveReference(refpath string) (ObjectDefinition, error) {
 	filenamePrefixIndex := strings.Index(refpath, `/`)
 	filename := refpath[:filenamePrefixIndex-1]
@@ -31,13 +38,23 @@ func (p *Parser) resolveReference(refpath string) (ObjectDefinition, error) {
 	switch filename {
 	case "objects.json":
 		js = p.objects.Get(gjsonPath)
+		if !js.Exists() {
+			return ObjectDefinition{}, fmt.Errorf("%s: definition not found", refpath)
+		}
 	case "responses.json":
-		return ObjectDefinition{
-			Name: objectName,
-		}, nil
+		if !p.responses.Exists() {
+			return ObjectDefinition{
+				Name: objectName,
+			}, nil
+		}
+		js = p.responses.Get(gjsonPath)
+		if !js.Exists() {
+			return ObjectDefinition{}, fmt.Errorf("%s: definition not found", refpath)
+		}
+		// method returns value of response property
+		js = js.Get("properties.response")
 	default:
-		fmt.Println(refpath)
-		panic("unsupported resolving file: " + filename)
+		return ObjectDefinition{}, fmt.Errorf("%s: unsupported resolving file %s", refpath, filename)
 	}
 
 	expr, err := p.parseObjectExpression(js)
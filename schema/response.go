@@ -2,6 +2,7 @@ package schema
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/tidwall/gjson"
 )
@@ -32,6 +33,10 @@ func (p *Parser) ParseResponses(schema []byte) ([]ResponseDefinition, error) {
 		})
 		return true
 	})
+	// generated code must not depend on order of definitions in schema
+	sort.SliceStable(defs, func(i, j int) bool {
+		return defs[i].Name < defs[j].Name
+	})
 	return defs, err
 }
 
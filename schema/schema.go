@@ -13,6 +13,7 @@ const (
 	MethodsSchema   SchemaType = "methods.json"
 	ObjectsSchema   SchemaType = "objects.json"
 	ResponsesSchema SchemaType = "responses.json"
+	ErrorsSchema    SchemaType = "errors.json"
 	UnknownSchema   SchemaType = "unknown"
 	repoMasterURL              = "https://github.com/VKCOM/vk-api-schema/blob/master/"
 )
@@ -22,6 +23,10 @@ func DetectSchemaType(val gjson.Result) SchemaType {
 		return MethodsSchema
 	}
 
+	if e := val.Get("errors"); e.Exists() && e.IsObject() {
+		return ErrorsSchema
+	}
+
 	if t := val.Get("title"); t.Exists() {
 		if t.String() == "objects" {
 			return ObjectsSchema
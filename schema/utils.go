@@ -1,6 +1,23 @@
 package schema
 
-import "strings"
+import (
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// parseDeprecated parses "deprecated" key, which is either boolean or
+// deprecation message.
+func parseDeprecated(val gjson.Result) (bool, string) {
+	deprecated := val.Get("deprecated")
+	switch deprecated.Type {
+	case gjson.True:
+		return true, ""
+	case gjson.String:
+		return true, deprecated.String()
+	}
+	return false, ""
+}
 
 func resolveReferenceName(refpath string) string {
 	objectNameIndex := strings.LastIndex(refpath, `/`)
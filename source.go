@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SchemaSource reads schema files from disk or downloads them by URL.
+// Downloaded schemas are cached to temporary files, so every generation
+// pass reads the same schema revision.
+type SchemaSource struct {
+	client *http.Client
+
+	mu     sync.Mutex
+	cached map[string]string
+}
+
+func NewSchemaSource(timeout time.Duration) *SchemaSource {
+	return &SchemaSource{
+		client: &http.Client{Timeout: timeout},
+		cached: make(map[string]string),
+	}
+}
+
+func isURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// Read returns schema by file path or URL.
+func (s *SchemaSource) Read(path string) ([]byte, error) {
+	if !isURL(path) {
+		return ioutil.ReadFile(path)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if name, ok := s.cached[path]; ok {
+		return ioutil.ReadFile(name)
+	}
+
+	name, err := s.download(path)
+	if err != nil {
+		return nil, err
+	}
+	s.cached[path] = name
+	return ioutil.ReadFile(name)
+}
+
+func (s *SchemaSource) download(url string) (string, error) {
+	resp, err := s.client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: unexpected status %s", url, resp.Status)
+	}
+
+	f, err := ioutil.TempFile("", "vkgen-*.json")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("%s: %w", url, err)
+	}
+	return f.Name(), nil
+}
+
+// Close removes cached schemas.
+func (s *SchemaSource) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for url, name := range s.cached {
+		if err := os.Remove(name); err != nil {
+			return err
+		}
+		delete(s.cached, url)
+	}
+	return nil
+}